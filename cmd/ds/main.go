@@ -1,12 +1,14 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/bryanbarcelona/data-symmetry/internal/build"
 	"github.com/bryanbarcelona/data-symmetry/internal/cachewhack"
 	"github.com/bryanbarcelona/data-symmetry/internal/dupekill"
 	"github.com/bryanbarcelona/data-symmetry/internal/junksweep"
+	"github.com/bryanbarcelona/data-symmetry/internal/scan"
 	"github.com/bryanbarcelona/data-symmetry/internal/twincheck"
 	"github.com/spf13/cobra"
 )
@@ -14,11 +16,20 @@ import (
 func main() {
 	root := &cobra.Command{Use: "ds"}
 	root.Version = build.Version
+	root.SetVersionTemplate(build.Info() + "\n")
+	root.PersistentFlags().Bool("dry-run", false, "scan and report what would happen without modifying anything or prompting")
+	root.AddCommand(versionCmd)
 	root.AddCommand(junksweep.Cmd)
 	root.AddCommand(twincheck.Cmd)
 	root.AddCommand(dupekill.Cmd)
 	root.AddCommand(cachewhack.Cmd)
+	root.AddCommand(scan.Cmd)
 	if err := root.Execute(); err != nil {
+		// Commands may pick their own status, e.g. 2 for a partial failure
+		var coded interface{ ExitCode() int }
+		if errors.As(err, &coded) {
+			os.Exit(coded.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/build"
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version and build metadata",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		short, _ := cmd.Flags().GetBool("short")
+		if short {
+			fmt.Println(build.Version)
+			return nil
+		}
+		fmt.Println(build.Info())
+		return nil
+	},
+}
+
+func init() {
+	versionCmd.Flags().Bool("short", false, "print only the version number")
+}
@@ -2,9 +2,16 @@ module github.com/bryanbarcelona/data-symmetry
 
 go 1.24.3
 
-require github.com/spf13/cobra v1.10.1
+require (
+	github.com/pkg/sftp v1.13.9
+	github.com/spf13/cobra v1.10.1
+	golang.org/x/crypto v0.43.0
+	golang.org/x/text v0.30.0
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sys v0.37.0 // indirect
 )
@@ -1,3 +1,19 @@
 package build
 
-var Version = "0.4.0"
+import (
+	"fmt"
+	"runtime"
+)
+
+// Populated at link time via -ldflags "-X .../internal/build.<Var>=..."
+var (
+	Version = "0.4.0"
+	Commit  = "none"
+	Date    = "unknown"
+)
+
+// Info returns the version together with commit, build date and Go runtime.
+func Info() string {
+	return fmt.Sprintf("ds %s\ncommit: %s\nbuilt:  %s\ngo:     %s %s/%s",
+		Version, Commit, Date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
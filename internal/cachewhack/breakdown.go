@@ -0,0 +1,95 @@
+package cachewhack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// breakdownTop is how many of a folder's largest entries --simulate-empty
+// lists; the rest are summed into one line.
+const breakdownTop = 5
+
+// child is one top-level entry of a cache folder with its total size.
+type child struct {
+	name string
+	size int64
+	dir  bool
+}
+
+// breakdown sizes each top-level entry of path, largest first, and returns
+// them with their total. It walks the folder once, like dirStats, and
+// raises newest the same way.
+func breakdown(path string, newest *time.Time) ([]child, int64, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	children := make([]child, 0, len(entries))
+	for _, e := range entries {
+		c := child{name: e.Name(), dir: e.IsDir()}
+		if c.dir {
+			c.size, err = dirStats(filepath.Join(path, e.Name()), newest)
+		} else {
+			var info os.FileInfo
+			if info, err = e.Info(); err == nil {
+				c.size = info.Size()
+				if info.ModTime().After(*newest) {
+					*newest = info.ModTime()
+				}
+			}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		total += c.size
+		children = append(children, c)
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].size != children[j].size {
+			return children[i].size > children[j].size
+		}
+		return children[i].name < children[j].name
+	})
+	return children, total, nil
+}
+
+// writeBreakdown prints what emptying each folder would clear: its largest
+// top-level entries and a line for the rest.
+func writeBreakdown(w io.Writer, folders []folder) {
+	fmt.Fprintln(w, "Empty simulation: --empty clears each folder's contents and keeps the folder; deleting")
+	fmt.Fprintln(w, "reclaims the same space and removes the folder too.")
+	for _, f := range folders {
+		if f.sizeErr != nil {
+			fmt.Fprintf(w, "\n%s: size unknown (%v)\n", f.path, f.sizeErr)
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: emptying reclaims %s\n", f.path, humanSize(f.size))
+		if len(f.children) == 0 {
+			fmt.Fprintln(w, "  (already empty)")
+			continue
+		}
+		shown := f.children
+		if len(shown) > breakdownTop {
+			shown = shown[:breakdownTop]
+		}
+		for _, c := range shown {
+			name := c.name
+			if c.dir {
+				name += string(filepath.Separator)
+			}
+			fmt.Fprintf(w, "  %-40s %s\n", name, humanSize(c.size))
+		}
+		if rest := f.children[len(shown):]; len(rest) > 0 {
+			var size int64
+			for _, c := range rest {
+				size += c.size
+			}
+			fmt.Fprintf(w, "  ... and %d more entries, %s\n", len(rest), humanSize(size))
+		}
+	}
+}
@@ -3,20 +3,45 @@ package cachewhack
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	dryRun bool
-	force  bool
-	empty  bool
+	dryRun           bool
+	force            bool
+	empty            bool
+	assumeYes        bool
+	noSize           bool
+	simulateEmpty    bool
+	verify           bool
+	retry            bool
+	homeDir          string
+	extraRoots       []string
+	includeRoots     []string
+	excludePatterns  []string
+	sortBy           string
+	verbose          bool
+	format           string
+	maxFolders       int
+	skipCategoryKeys []string
+	skipCategories   map[string]bool
+	strictConfig     bool
+	strictPatterns   []string
+	summaryPath      string
+	historyPath      string
+	historyReport    bool
+
+	// progress receives scan progress; stderr with --format json
+	progress io.Writer = os.Stdout
 )
 
 type scanRoot struct {
@@ -24,46 +49,97 @@ type scanRoot struct {
 	maxDepth int // 0 = unlimited, 1 = direct children only
 }
 
-// systemScanRoots defines all filesystem entry points and their scan rules.
+// systemScanRoots defines all filesystem entry points and their scan rules
+// for the current user and machine.
 func systemScanRoots() []scanRoot {
-	var roots []scanRoot
+	home, _ := os.UserHomeDir()
+	roots := machineScanRoots()
+	return append(roots, userScanRoots(home, os.Getenv("LOCALAPPDATA"), os.Getenv("APPDATA"))...)
+}
 
+// homeScanRoots resolves the per-user cache locations under another home
+// directory, e.g. a different account or a mounted backup. System-wide
+// locations belong to the running machine and are left out.
+func homeScanRoots(home string) []scanRoot {
+	return userScanRoots(home,
+		filepath.Join(home, "AppData", "Local"),
+		filepath.Join(home, "AppData", "Roaming"))
+}
+
+// machineScanRoots are system-wide locations not tied to any user.
+func machineScanRoots() []scanRoot {
 	switch runtime.GOOS {
 	case "windows":
-		roots = append(roots,
-			scanRoot{os.Getenv("LOCALAPPDATA"), 1}, // Tempzxpsign* lives here
-			scanRoot{filepath.Join(os.Getenv("WINDIR"), "Temp"), 0},
-			// scanRoot{filepath.Join(os.Getenv("WINDIR"), "SoftwareDistribution", "Download"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "pip", "Cache"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "npm-cache"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "JetBrains"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Teams", "Cache"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "Google", "Chrome", "User Data"), 0},
-			scanRoot{filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Edge", "User Data"), 0},
-			scanRoot{filepath.Join(os.Getenv("APPDATA"), "Code", "Cache"), 0},
-			scanRoot{filepath.Join(os.Getenv("APPDATA"), "Adobe"), 0},
-		)
-
+		return []scanRoot{
+			{filepath.Join(os.Getenv("WINDIR"), "Temp"), 0},
+			// {filepath.Join(os.Getenv("WINDIR"), "SoftwareDistribution", "Download"), 0},
+		}
 	case "darwin":
-		home, _ := os.UserHomeDir()
-		roots = append(roots,
-			scanRoot{"/Library/Caches/Adobe", 0},
-			scanRoot{filepath.Join(home, "Library", "Caches"), 0},
-		)
+		return []scanRoot{{"/Library/Caches/Adobe", 0}}
+	case "linux":
+		return []scanRoot{{"/tmp", 0}, {"/var/tmp", 0}}
+	}
+	return nil
+}
 
+// userScanRoots are the per-user cache locations. localAppData and appData
+// are only used on Windows.
+func userScanRoots(home, localAppData, appData string) []scanRoot {
+	switch runtime.GOOS {
+	case "windows":
+		return []scanRoot{
+			{localAppData, 1}, // Tempzxpsign* lives here
+			{filepath.Join(localAppData, "pip", "Cache"), 0},
+			{filepath.Join(localAppData, "npm-cache"), 0},
+			{filepath.Join(localAppData, "JetBrains"), 0},
+			{filepath.Join(localAppData, "Microsoft", "Teams", "Cache"), 0},
+			{filepath.Join(localAppData, "Google", "Chrome", "User Data"), 0},
+			{filepath.Join(localAppData, "Microsoft", "Edge", "User Data"), 0},
+			{filepath.Join(appData, "Code", "Cache"), 0},
+			{filepath.Join(appData, "Adobe"), 0},
+		}
+	case "darwin":
+		return []scanRoot{{filepath.Join(home, "Library", "Caches"), 0}}
 	case "linux":
-		home, _ := os.UserHomeDir()
-		roots = append(roots,
-			scanRoot{"/tmp", 0},
-			scanRoot{"/var/tmp", 0},
-			scanRoot{filepath.Join(home, ".cache"), 0},
-		)
+		return []scanRoot{{filepath.Join(home, ".cache"), 0}}
 	}
+	return nil
+}
 
+// activeScanRoots returns the built-in roots, or those derived from --home
+// plus any --root paths when either override is given. --strict-config
+// uses the --root paths alone.
+func activeScanRoots() []scanRoot {
+	if !strictConfig && homeDir == "" && len(extraRoots) == 0 {
+		return systemScanRoots()
+	}
+	var roots []scanRoot
+	if homeDir != "" {
+		roots = homeScanRoots(homeDir)
+	}
+	for _, r := range extraRoots {
+		roots = append(roots, scanRoot{r, 0})
+	}
 	return roots
 }
 
+// existingRoots filters roots down to directories that exist.
+func existingRoots(roots []scanRoot) []scanRoot {
+	var found []scanRoot
+	for _, sr := range roots {
+		if sr.path == "" {
+			continue
+		}
+		if info, err := os.Stat(sr.path); err == nil && info.IsDir() {
+			found = append(found, sr)
+		}
+	}
+	return found
+}
+
 // matchCacheFolder reports whether a folder name matches any glob pattern.
+// With --strict-config only the --pattern globs count; the refusals and
+// --exclude-pattern still apply.
 func matchCacheFolder(name string) bool {
 	name = strings.ToLower(name)
 
@@ -71,12 +147,20 @@ func matchCacheFolder(name string) bool {
 	if name == "package cache" || name == "slstore" {
 		return false
 	}
+	for _, p := range excludePatterns {
+		if matched, _ := filepath.Match(strings.ToLower(p), name); matched {
+			return false
+		}
+	}
+	if strictConfig {
+		return strictPattern(name) != ""
+	}
 
 	pats := []string{
 		"cache", "*cache*", "glcache", "inetcache", "webcache",
 		"cacheddata", "npm-cache", "pip",
 		"consentoptions", "webkit", "code cache", "gpucache",
-		"bluestacks", "pypa", "squirreltemp", "go", "go-build", "vcpkg",
+		"bluestacks", "pypa", "squirreltemp", "go-build", "vcpkg",
 		// Adobe / Photoshop temp junk
 		"tempzxpsign*", "photoshop temp*", "adobetemp*", "bridgecache*",
 	}
@@ -98,22 +182,15 @@ func depth(root, path string) int {
 	return len(strings.Split(rel, string(os.PathSeparator)))
 }
 
-// findWhackable returns absolute paths of cache folders to delete.
-func findWhackable() []string {
-	var out []string
-
-	for _, sr := range systemScanRoots() {
-		if sr.path == "" {
-			continue
-		}
-		info, err := os.Stat(sr.path)
-		if err != nil || !info.IsDir() {
-			continue
-		}
+// findWhackable returns the cache folders to delete under the given
+// (existing) roots, each labelled with its category.
+func findWhackable(roots []scanRoot) []folder {
+	var out []folder
 
+	for _, sr := range roots {
 		// Root itself may be whackable
 		if matchCacheFolder(filepath.Base(sr.path)) {
-			out = append(out, sr.path)
+			out = append(out, folder{path: sr.path, category: categorize(sr.path, sr), root: sr.path})
 			continue
 		}
 
@@ -125,7 +202,7 @@ func findWhackable() []string {
 				return filepath.SkipDir
 			}
 			if d.IsDir() && matchCacheFolder(d.Name()) {
-				out = append(out, path)
+				out = append(out, folder{path: path, category: categorize(path, sr), root: sr.path})
 				return filepath.SkipDir
 			}
 			return nil
@@ -135,6 +212,66 @@ func findWhackable() []string {
 	return out
 }
 
+// addIncludeRoots adds each --include-root directory to the candidates as
+// a whole, whatever its name, and drops candidates inside it since they
+// go with it. Missing paths, non-directories, filesystem roots and the
+// home directory are refused.
+func addIncludeRoots(folders []folder, paths []string) ([]folder, error) {
+	home, _ := os.UserHomeDir()
+	var roots []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("--include-root %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("--include-root %s is not a directory", p)
+		}
+		if filepath.Dir(abs) == abs || (home != "" && abs == filepath.Clean(home)) {
+			return nil, fmt.Errorf("refusing --include-root %s: it is a filesystem root or your home directory", p)
+		}
+		roots = append(roots, abs)
+	}
+
+	under := func(path string) bool {
+		for _, r := range roots {
+			if path == r || strings.HasPrefix(path, r+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	kept := folders[:0]
+	for _, f := range folders {
+		if !under(f.path) {
+			kept = append(kept, f)
+		}
+	}
+	seen := make(map[string]bool, len(roots))
+	for _, r := range roots {
+		if seen[r] || containsAncestor(roots, r) {
+			continue
+		}
+		seen[r] = true
+		kept = append(kept, folder{path: r, category: categorize(r, scanRoot{path: r})})
+	}
+	return kept, nil
+}
+
+// containsAncestor reports whether another path in roots contains path.
+func containsAncestor(roots []string, path string) bool {
+	for _, r := range roots {
+		if r != path && strings.HasPrefix(path, r+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // emptyDir removes all contents of a directory without deleting the directory itself.
 func emptyDir(path string) error {
 	entries, err := os.ReadDir(path)
@@ -149,8 +286,20 @@ func emptyDir(path string) error {
 	return nil
 }
 
-// whack deletes (or empties) the list concurrently.
-func whack(paths []string) {
+// whackResult aggregates the outcome of a whack run.
+type whackResult struct {
+	succeeded int
+	failed    int
+	freed     int64    // bytes, based on sizes measured before deletion
+	whacked   []string // folders cleared without error
+	failures  []whackFailure
+}
+
+// whack deletes (or empties) the list concurrently. sizes holds the
+// pre-computed size of each path and is used to account for freed bytes.
+func whack(paths []string, sizes map[string]int64) whackResult {
+	var res whackResult
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 8)
 
@@ -162,12 +311,7 @@ func whack(paths []string) {
 			defer func() { <-sem }()
 
 			if dryRun {
-				fmt.Println("[dry-run] would", func() string {
-					if empty {
-						return "empty"
-					}
-					return "delete"
-				}(), ":", p)
+				fmt.Println("[dry-run] would", action(), ":", p)
 				return
 			}
 
@@ -178,18 +322,27 @@ func whack(paths []string) {
 				err = os.RemoveAll(p)
 			}
 
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
 				log.Printf("failed on %s: %v", p, err)
+				res.failed++
+				res.failures = append(res.failures, whackFailure{Path: p, Error: err.Error()})
 			} else {
 				log.Println("whacked:", p)
+				res.succeeded++
+				res.freed += sizes[p]
+				res.whacked = append(res.whacked, p)
 			}
 		}(p)
 	}
 	wg.Wait()
+	return res
 }
 
-// dirSize calculates total size of a directory
-func dirSize(path string) (int64, error) {
+// dirStats calculates total size of a directory and raises newest to the
+// latest file modification time seen in it
+func dirStats(path string, newest *time.Time) (int64, error) {
 	var size int64
 	err := filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -201,6 +354,9 @@ func dirSize(path string) (int64, error) {
 				return err
 			}
 			size += info.Size()
+			if info.ModTime().After(*newest) {
+				*newest = info.ModTime()
+			}
 		}
 		return nil
 	})
@@ -236,87 +392,281 @@ func humanSize(bytes int64) string {
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	if !force {
-		dryRun = true
+	globalDryRun, _ := cmd.Flags().GetBool("dry-run")
+	dryRun = globalDryRun || !force
+	for _, p := range excludePatterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid --exclude-pattern %q: %w", p, err)
+		}
+	}
+
+	if historyReport {
+		if historyPath == "" {
+			return fmt.Errorf("--history-report needs --history <file> to read")
+		}
+		entries, err := loadHistory(historyPath)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No entries in %s yet. Record some with dry runs and --history.\n", historyPath)
+			return nil
+		}
+		return writeHistoryReport(os.Stdout, summarizeGrowth(entries))
+	}
+	if historyPath != "" && (noSize || !dryRun) {
+		return fmt.Errorf("--history records the sizes of a dry run; drop --force and --no-size")
 	}
 
-	targets := findWhackable()
-	if len(targets) == 0 {
+	if err := checkStrictConfig(); err != nil {
+		return err
+	}
+	if simulateEmpty && (noSize || !dryRun) {
+		return fmt.Errorf("--simulate-empty is a dry-run report that needs sizes; drop --force and --no-size")
+	}
+	if maxFolders < 0 {
+		return fmt.Errorf("--max must not be negative")
+	}
+	var err error
+	if skipCategories, err = parseSkipCategories(skipCategoryKeys); err != nil {
+		return err
+	}
+	switch sortBy {
+	case "size", "mtime", "path":
+	default:
+		return fmt.Errorf("invalid --sort: %s (use: size, mtime, path)", sortBy)
+	}
+	switch format {
+	case "table":
+		progress = os.Stdout
+	case "json":
+		if !dryRun {
+			return fmt.Errorf("--format json is for dry-run listings; drop --force or add --dry-run")
+		}
+		// Keep stdout pure JSON
+		progress = os.Stderr
+	default:
+		return fmt.Errorf("invalid --format: %s (use: table, json)", format)
+	}
+
+	all := activeScanRoots()
+	roots := existingRoots(all)
+	fmt.Fprintf(progress, "Scanning %d of %d cache roots:\n", len(roots), len(all))
+	for _, sr := range roots {
+		fmt.Fprintf(progress, "  %s\n", sr.path)
+	}
+	if len(roots) == 0 && len(includeRoots) == 0 {
+		if verbose {
+			writeRootCoverage(progress, all, nil)
+		}
+		return fmt.Errorf("none of the cache roots exist")
+	}
+
+	folders, err := addIncludeRoots(findWhackable(roots), includeRoots)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		writeRootCoverage(progress, all, folders)
+	}
+	if strictConfig {
+		writeStrictMatches(progress, folders)
+	}
+	found := len(folders)
+	kept := folders[:0]
+	for _, f := range folders {
+		if !skipCategories[f.category] {
+			kept = append(kept, f)
+		}
+	}
+	folders = kept
+	if len(folders) == 0 {
+		if err := writeSummary(runSummary{DryRun: dryRun}); err != nil {
+			return err
+		}
+		if format == "json" {
+			return writeJSON(os.Stdout, nil)
+		}
 		fmt.Println("No cache folders found to whack.")
 		return nil
 	}
 
-	fmt.Printf("Found %d cache folders.\n", len(targets))
+	if skipped := found - len(folders); skipped > 0 {
+		fmt.Fprintf(progress, "Found %d cache folders (%d more in skipped categories).\n", len(folders), skipped)
+	} else {
+		fmt.Fprintf(progress, "Found %d cache folders.\n", len(folders))
+	}
+
+	gatherFolders(folders)
+	sortFolders(folders, sortBy)
+	if maxFolders > 0 && len(folders) > maxFolders {
+		fmt.Fprintf(progress, "Limiting to the first %d of %d folders (--max, ordered by --sort %s).\n", maxFolders, len(folders), sortBy)
+		folders = folders[:maxFolders]
+	}
 
 	var totalBytes int64
-	for _, p := range targets {
-		if dryRun {
-			fmt.Printf("[dry-run] would %s : %s", func() string {
-				if empty {
-					return "empty"
-				}
-				return "delete"
-			}(), p)
+	sizes := make(map[string]int64, len(folders))
+	byPath := make(map[string]folder, len(folders))
+	targets := make([]string, 0, len(folders))
+	for _, f := range folders {
+		targets = append(targets, f.path)
+		byPath[f.path] = f
+		if !noSize && f.sizeErr == nil {
+			totalBytes += f.size
+			sizes[f.path] = f.size
 		}
+	}
 
-		size, err := dirSize(p)
-		if err != nil {
-			if dryRun {
-				fmt.Printf(" (size unknown: %v)", err)
-			}
-		} else {
-			totalBytes += size
-			if dryRun {
-				fmt.Printf(" (%s)", humanSize(size))
+	summary := runSummary{FoldersFound: len(folders), DryRun: dryRun}
+	if dryRun {
+		if err := writeSummary(summary); err != nil {
+			return err
+		}
+		if historyPath != "" {
+			if err := appendHistory(historyPath, folders, time.Now()); err != nil {
+				return err
 			}
+			fmt.Fprintf(progress, "Recorded %d folder sizes in %s.\n", len(folders), historyPath)
+		}
+		if format == "json" {
+			return writeJSON(os.Stdout, folders)
+		}
+		fmt.Println()
+		if err := writeTable(os.Stdout, folders); err != nil {
+			return err
 		}
-		if dryRun {
+		fmt.Println()
+		if simulateEmpty {
+			writeBreakdown(os.Stdout, folders)
 			fmt.Println()
 		}
-	}
-
-	if dryRun {
-		fmt.Printf("\nPotential space to reclaim: %s\n", humanSize(totalBytes))
-		fmt.Println("Re-run with --force to actually delete/empty.")
+		writeCategorySummary(os.Stdout, folders)
+		fmt.Printf("Potential space to reclaim: %s\n", totalSize(totalBytes))
+		if globalDryRun {
+			fmt.Println("Dry-run enabled. Nothing was deleted.")
+		} else {
+			fmt.Println("Re-run with --force to actually delete/empty.")
+		}
 		return nil
 	}
 
-	fmt.Printf("\nThis will %s %d cache folders and free approximately %s of space.\n",
-		func() string {
-			if empty {
-				return "empty"
-			}
-			return "delete"
-		}(), len(targets), humanSize(totalBytes))
-	fmt.Print("This is irreversible. Continue? (y/N): ")
+	if !assumeYes {
+		scanner := bufio.NewScanner(os.Stdin)
+		targets = pickTargets(scanner, targets, sizes)
+		if len(targets) == 0 {
+			fmt.Println("Nothing selected. Aborted.")
+			return abortRun(summary, scanner.Err())
+		}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
+		totalBytes = 0
+		selected := make([]folder, 0, len(targets))
+		for _, p := range targets {
+			totalBytes += sizes[p]
+			selected = append(selected, byPath[p])
+		}
+		fmt.Println()
+		writeCategorySummary(os.Stdout, selected)
+		verb := "delete"
+		if empty {
+			verb = "empty"
+		}
+		if noSize {
+			fmt.Printf("This will %s %d cache folders (sizes not computed).\n", verb, len(targets))
+		} else {
+			fmt.Printf("This will %s %d cache folders and free approximately %s of space.\n",
+				verb, len(targets), humanSize(totalBytes))
+		}
+		fmt.Print("This is irreversible. Continue? (y/N): ")
+
+		if !scanner.Scan() {
+			fmt.Println("Aborted.")
+			return abortRun(summary, scanner.Err())
+		}
 		input := strings.ToLower(strings.TrimSpace(scanner.Text()))
 		if input != "y" && input != "yes" {
 			fmt.Println("Aborted.")
-			return nil
+			return abortRun(summary, nil)
+		}
+	}
+
+	volumes := measureVolumes(targets)
+	res := whack(targets, sizes)
+	fmt.Println("System cache whack complete.")
+	fmt.Printf("Cleared %d folders, failed %d, freed %s.\n", res.succeeded, res.failed, totalSize(res.freed))
+
+	var resisted []string
+	if verify || retry {
+		resisted = verifyWhacked(res.whacked, retry)
+		if len(resisted) == 0 {
+			fmt.Printf("Verified %d folders stayed cleared.\n", len(res.whacked))
+		} else {
+			fmt.Printf("\n%d folders were recreated or not fully cleared (close the applications using them and re-run):\n", len(resisted))
+			for _, p := range resisted {
+				fmt.Println("  " + p)
+			}
 		}
 	}
-	if err := scanner.Err(); err != nil {
+
+	if len(volumes) > 0 {
+		fmt.Println("\nFree space:")
+		writeFreeSpace(os.Stdout, volumes)
+	}
+
+	summary.FoldersCleared = res.succeeded - len(resisted)
+	summary.BytesFreed = res.freed
+	summary.Failures = res.failures
+	for _, p := range resisted {
+		summary.BytesFreed -= sizes[p]
+		summary.Failures = append(summary.Failures, whackFailure{Path: p, Error: "recreated or not fully cleared"})
+	}
+	if err := writeSummary(summary); err != nil {
 		return err
 	}
 
-	whack(targets)
-	fmt.Println("System cache whack complete.")
+	if res.failed > 0 || len(resisted) > 0 {
+		return partialFailure{failed: res.failed + len(resisted)}
+	}
 	return nil
 }
 
+// totalSize formats a byte total, or notes that --no-size skipped it.
+func totalSize(n int64) string {
+	if noSize {
+		return "sizes not computed"
+	}
+	return humanSize(n)
+}
+
 // THIS IS THE MISSING PIECE THAT FIXES YOUR COMPILER ERROR
 var Cmd = &cobra.Command{
-	Use:   "cachewhack",
-	Short: "System-wide cache folder exterminator",
-	RunE:  run,
+	Use:          "cachewhack",
+	Short:        "System-wide cache folder exterminator",
+	RunE:         run,
+	SilenceUsage: true,
 }
 
 func init() {
 	Cmd.Flags().BoolVarP(&force, "force", "f", false, "actually delete/empty (default is dry-run)")
 	Cmd.Flags().BoolVarP(&empty, "empty", "e", false, "empty folders instead of deleting them")
+	Cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "with --force, whack every folder without the picker or confirmation")
+	Cmd.Flags().StringVar(&homeDir, "home", "", "resolve per-user cache locations under this home directory instead of yours (system-wide locations are skipped)")
+	Cmd.Flags().StringArrayVar(&includeRoots, "include-root", nil, "always whack this directory as a whole, even if its name matches no cache pattern (repeatable)")
+	Cmd.Flags().BoolVar(&strictConfig, "strict-config", false, "ignore the built-in locations and name patterns; consider only --include-root paths and folders under --root matching --pattern")
+	Cmd.Flags().StringArrayVar(&strictPatterns, "pattern", nil, "with --strict-config, folder name glob to whack under --root (repeatable, case-insensitive)")
+	Cmd.Flags().StringArrayVar(&extraRoots, "root", nil, "scan this directory for cache folders instead of the built-in locations (repeatable; combines with --home)")
+	Cmd.Flags().BoolVar(&verify, "verify", false, "after whacking, re-check each folder and report any that were recreated or not fully cleared")
+	Cmd.Flags().BoolVar(&retry, "retry", false, "verify, and whack folders that resisted once more before reporting them (implies --verify)")
+	Cmd.Flags().BoolVar(&noSize, "no-size", false, "skip walking folders to total their size (faster listing; totals show as not computed)")
+	Cmd.Flags().BoolVar(&simulateEmpty, "simulate-empty", false, "dry-run: show what emptying each folder would clear, with its largest top-level entries")
+	Cmd.Flags().IntVar(&maxFolders, "max", 0, "whack at most N folders, taken from the top of the --sort order (0 = no limit)")
+	Cmd.Flags().StringSliceVar(&skipCategoryKeys, "skip-category", nil, "leave a whole class of caches alone: browser, package-manager, ide, adobe, os-temp, other (repeatable)")
+	Cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "list every configured root with what became of it: not found, no caches, or how many caches it held")
+	Cmd.Flags().StringVar(&sortBy, "sort", "size", "order of the listing: size (largest first) | mtime (stalest first) | path")
+	Cmd.Flags().StringVar(&format, "format", "table", "dry-run listing format: table | json")
+	Cmd.Flags().StringVar(&summaryPath, "summary-json", "", "write a JSON summary of the run (folders found and cleared, bytes freed, failures) to this path, for schedulers")
+	Cmd.Flags().StringVar(&historyPath, "history", "", "dry-run: append each folder's size with a timestamp to this JSON Lines file, to track cache growth over time")
+	Cmd.Flags().BoolVar(&historyReport, "history-report", false, "summarize the --history file (growth per day, fastest first) and exit without scanning")
+	Cmd.Flags().StringSliceVar(&excludePatterns, "exclude-pattern", nil, "folder name glob to never whack (repeatable, case-insensitive)")
 }
 
 // func run(cmd *cobra.Command, args []string) error {
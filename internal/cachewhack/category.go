@@ -0,0 +1,103 @@
+package cachewhack
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// categories are the kinds of cache folders, in summary order. The key is
+// what --skip-category takes.
+var categories = []struct {
+	key, label string
+	patterns   []string // globs matched against each lower-cased path segment
+}{
+	{"browser", "Browsers", []string{"chrome", "google-chrome*", "chromium", "edge", "microsoft-edge*", "firefox", "mozilla", "safari", "com.apple.safari", "brave*", "opera*", "inetcache", "webkit"}},
+	{"package-manager", "Package managers", []string{"npm", "npm-cache", "_npx", "pip", "pypa", "yarn", "pnpm", "go-build*", "vcpkg", "cargo", ".cargo", "gradle", ".gradle", ".m2", "nuget*", "homebrew"}},
+	{"ide", "IDEs and editors", []string{"jetbrains", "code", "code - insiders", "vscode*", "xcode", "visual studio*"}},
+	{"adobe", "Adobe", []string{"adobe*", "tempzxpsign*", "photoshop*", "bridgecache*"}},
+	{"os-temp", "OS temp", nil},
+	{"other", "Other", nil},
+}
+
+// categorize labels a matched folder by the application its path names
+// below the scan root, falling back to os-temp for folders found under a
+// temp root. Only the segments under the root count, so a user or mount
+// named after an application does not label every folder; a folder that
+// is the root itself is judged by its own name. The deepest naming segment
+// wins, so Code/User/.../Chrome is a browser cache, not an IDE one.
+func categorize(path string, root scanRoot) string {
+	rel, err := filepath.Rel(root.path, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(path)
+	}
+	segments := strings.Split(strings.ToLower(rel), string(filepath.Separator))
+	for i := len(segments) - 1; i >= 0; i-- {
+		for _, c := range categories {
+			for _, p := range c.patterns {
+				if ok, _ := filepath.Match(p, segments[i]); ok {
+					return c.key
+				}
+			}
+		}
+	}
+	switch strings.ToLower(filepath.Base(root.path)) {
+	case "temp", "tmp":
+		return "os-temp"
+	}
+	return "other"
+}
+
+// parseSkipCategories validates --skip-category values.
+func parseSkipCategories(keys []string) (map[string]bool, error) {
+	skip := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		k = strings.ToLower(strings.TrimSpace(k))
+		known := false
+		for _, c := range categories {
+			if c.key == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			var valid []string
+			for _, c := range categories {
+				valid = append(valid, c.key)
+			}
+			return nil, fmt.Errorf("unknown --skip-category %q (use: %s)", k, strings.Join(valid, ", "))
+		}
+		skip[k] = true
+	}
+	return skip, nil
+}
+
+// writeCategorySummary prints folder count and size per category, e.g.
+// "Browsers: 3 folders, 1.2 GB".
+func writeCategorySummary(w io.Writer, folders []folder) {
+	count := make(map[string]int)
+	bytes := make(map[string]int64)
+	for _, f := range folders {
+		count[f.category]++
+		if f.sizeErr == nil {
+			bytes[f.category] += f.size
+		}
+	}
+	fmt.Fprintln(w, "By category:")
+	for _, c := range categories {
+		n := count[c.key]
+		if n == 0 {
+			continue
+		}
+		noun := "folders"
+		if n == 1 {
+			noun = "folder"
+		}
+		if noSize {
+			fmt.Fprintf(w, "  %s: %d %s\n", c.label, n, noun)
+		} else {
+			fmt.Fprintf(w, "  %s: %d %s, %s\n", c.label, n, noun, humanSize(bytes[c.key]))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cachewhack
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// volumeSpace tracks the free space of one volume across a whack run.
+type volumeSpace struct {
+	label  string // "Drive C:" or "Volume /home"
+	probe  string // a directory on the volume that outlives the run
+	before uint64
+}
+
+// measureVolumes records the free space of each volume holding one of the
+// folders. It probes the parent of each folder, which survives a delete.
+// Volumes whose free space cannot be read are left out.
+func measureVolumes(folders []string) []*volumeSpace {
+	var volumes []*volumeSpace
+	seen := make(map[string]bool)
+	for _, f := range folders {
+		probe := filepath.Dir(f)
+		id, label, ok := volumeOf(probe)
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		free, err := freeSpace(probe)
+		if err != nil {
+			continue
+		}
+		volumes = append(volumes, &volumeSpace{label: label, probe: probe, before: free})
+	}
+	return volumes
+}
+
+// writeFreeSpace reports each volume's free space before and after the run,
+// e.g. "Drive C: 40.0 GB free -> 54.0 GB free (+14.0 GB)". Space held by
+// files a program still has open is only released when it closes them, so
+// a gain short of the estimate points at such handles.
+func writeFreeSpace(w io.Writer, volumes []*volumeSpace) {
+	for _, v := range volumes {
+		after, err := freeSpace(v.probe)
+		if err != nil {
+			fmt.Fprintf(w, "  %s: %s free before; free space now unreadable: %v\n", v.label, shortSize(v.before), err)
+			continue
+		}
+		sign, delta := "+", after-v.before
+		if after < v.before {
+			sign, delta = "-", v.before-after
+		}
+		fmt.Fprintf(w, "  %s: %s free -> %s free (%s%s)\n", v.label, shortSize(v.before), shortSize(after), sign, shortSize(delta))
+	}
+}
+
+// shortSize formats bytes with one unit and decimal, e.g. "54.0 GB".
+func shortSize(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGT"[exp])
+}
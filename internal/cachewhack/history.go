@@ -0,0 +1,134 @@
+package cachewhack
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry is one line of the --history file: a folder's size as seen
+// by a dry run.
+type historyEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"sizeBytes"`
+}
+
+// appendHistory adds one entry per sized folder to the --history file, all
+// stamped with the same time so a run's entries group together.
+func appendHistory(path string, folders []folder, now time.Time) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening --history: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, fo := range folders {
+		if fo.sizeErr != nil {
+			continue
+		}
+		if err := enc.Encode(historyEntry{Timestamp: now.UTC(), Path: fo.path, SizeBytes: fo.size}); err != nil {
+			f.Close()
+			return fmt.Errorf("writing --history: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing --history: %w", err)
+	}
+	return nil
+}
+
+// loadHistory reads every entry of a --history file.
+func loadHistory(path string) ([]historyEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening --history: %w", err)
+	}
+	defer f.Close()
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// folderGrowth summarizes one folder's recorded sizes.
+type folderGrowth struct {
+	path    string
+	samples int
+	first   time.Time
+	last    time.Time
+	latest  int64
+	grown   int64 // sum of the increases between samples; drops from clearing are ignored
+}
+
+// perDay is the folder's average growth per day over the recorded span, or
+// -1 when a single sample (or one instant) gives no span to measure.
+func (g folderGrowth) perDay() float64 {
+	days := g.last.Sub(g.first).Hours() / 24
+	if days <= 0 {
+		return -1
+	}
+	return float64(g.grown) / days
+}
+
+// summarizeGrowth folds the entries into one summary per folder, fastest
+// growing first. Only increases count as growth, so a cache cleared between
+// two runs does not hide how quickly it filled up again.
+func summarizeGrowth(entries []historyEntry) []folderGrowth {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	byPath := make(map[string]*folderGrowth)
+	for _, e := range entries {
+		g, ok := byPath[e.Path]
+		if !ok {
+			byPath[e.Path] = &folderGrowth{path: e.Path, samples: 1, first: e.Timestamp, last: e.Timestamp, latest: e.SizeBytes}
+			continue
+		}
+		if e.SizeBytes > g.latest {
+			g.grown += e.SizeBytes - g.latest
+		}
+		g.samples++
+		g.last = e.Timestamp
+		g.latest = e.SizeBytes
+	}
+	growth := make([]folderGrowth, 0, len(byPath))
+	for _, g := range byPath {
+		growth = append(growth, *g)
+	}
+	sort.Slice(growth, func(i, j int) bool {
+		a, b := growth[i].perDay(), growth[j].perDay()
+		if a != b {
+			return a > b
+		}
+		return growth[i].path < growth[j].path
+	})
+	return growth
+}
+
+// writeHistoryReport prints the --history-report table.
+func writeHistoryReport(w io.Writer, growth []folderGrowth) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tSAMPLES\tSINCE\tLATEST SIZE\tGROWTH/DAY")
+	for _, g := range growth {
+		rate := "n/a"
+		if perDay := g.perDay(); perDay >= 0 {
+			rate = shortSize(uint64(perDay))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", g.path, g.samples, g.first.Local().Format("2006-01-02"), shortSize(uint64(g.latest)), rate)
+	}
+	return tw.Flush()
+}
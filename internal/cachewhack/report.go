@@ -0,0 +1,174 @@
+package cachewhack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// folder is a cache folder found by the scan, with the metadata shown in
+// the dry-run report.
+type folder struct {
+	path     string
+	category string // key into categories
+	size     int64
+	sizeErr  error     // size walk failed; size is unknown
+	modTime  time.Time // newest modification time of the folder or anything in it
+	children []child   // top-level entries, largest first (--simulate-empty)
+	root     string    // scan root it was found under; "" for --include-root
+}
+
+// gatherFolders walks each folder once for its size and newest mtime. With
+// --no-size the walk is skipped and only the folder's own mtime is used;
+// with --simulate-empty the same walk also sizes each top-level entry.
+func gatherFolders(folders []folder) {
+	for i := range folders {
+		f := &folders[i]
+		if info, err := os.Stat(f.path); err == nil {
+			f.modTime = info.ModTime()
+		}
+		switch {
+		case simulateEmpty:
+			f.children, f.size, f.sizeErr = breakdown(f.path, &f.modTime)
+		case !noSize:
+			f.size, f.sizeErr = dirStats(f.path, &f.modTime)
+		}
+	}
+}
+
+// sortFolders orders folders for --sort: size (largest first), mtime
+// (stalest first) or path.
+func sortFolders(folders []folder, by string) {
+	sort.SliceStable(folders, func(i, j int) bool {
+		a, b := folders[i], folders[j]
+		switch by {
+		case "size":
+			if a.size != b.size {
+				return a.size > b.size
+			}
+		case "mtime":
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.Before(b.modTime)
+			}
+		}
+		return a.path < b.path
+	})
+}
+
+// action is what a run would do to each folder.
+func action() string {
+	if empty {
+		return "empty"
+	}
+	return "delete"
+}
+
+func (f folder) sizeText() string {
+	switch {
+	case noSize:
+		return "not computed"
+	case f.sizeErr != nil:
+		return "unknown"
+	}
+	return humanSize(f.size)
+}
+
+func (f folder) modText() string {
+	if f.modTime.IsZero() {
+		return "unknown"
+	}
+	return f.modTime.Local().Format("2006-01-02 15:04")
+}
+
+// writeTable prints the dry-run report as aligned columns.
+func writeTable(w io.Writer, folders []folder) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tCATEGORY\tSIZE\tLAST MODIFIED\tACTION")
+	for _, f := range folders {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.path, f.category, f.sizeText(), f.modText(), action())
+	}
+	return tw.Flush()
+}
+
+// folderRecord is one entry of --format json output.
+type folderRecord struct {
+	Path         string        `json:"path"`
+	Category     string        `json:"category"`
+	Size         *int64        `json:"size,omitempty"` // absent with --no-size or when unreadable
+	SizeError    string        `json:"size_error,omitempty"`
+	LastModified *time.Time    `json:"last_modified,omitempty"`
+	Action       string        `json:"action"`
+	Contents     []childRecord `json:"contents,omitempty"` // --simulate-empty: top-level entries, largest first
+}
+
+type childRecord struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Dir  bool   `json:"dir,omitempty"`
+}
+
+// writeJSON prints the dry-run report as a JSON array.
+func writeJSON(w io.Writer, folders []folder) error {
+	records := make([]folderRecord, 0, len(folders))
+	for _, f := range folders {
+		r := folderRecord{Path: f.path, Category: f.category, Action: action()}
+		if !noSize {
+			if f.sizeErr != nil {
+				r.SizeError = f.sizeErr.Error()
+			} else {
+				size := f.size
+				r.Size = &size
+			}
+		}
+		if !f.modTime.IsZero() {
+			mt := f.modTime.UTC()
+			r.LastModified = &mt
+		}
+		for _, c := range f.children {
+			r.Contents = append(r.Contents, childRecord{Name: c.name, Size: c.size, Dir: c.dir})
+		}
+		records = append(records, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// writeRootCoverage lists every configured scan root with its disposition
+// for --verbose, so an absent cache can be told apart from a clean one.
+// Counts are taken before --skip-category filtering.
+func writeRootCoverage(w io.Writer, roots []scanRoot, folders []folder) {
+	found := make(map[string]int)
+	for _, f := range folders {
+		found[f.root]++
+	}
+	fmt.Fprintln(w, "Root coverage:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	for _, sr := range roots {
+		if sr.path == "" {
+			continue
+		}
+		status := "not found"
+		if info, err := os.Stat(sr.path); err == nil && !info.IsDir() {
+			status = "not a directory"
+		} else if err == nil {
+			switch n := found[sr.path]; n {
+			case 0:
+				status = "found, no caches"
+			case 1:
+				status = "found, 1 cache"
+			default:
+				status = fmt.Sprintf("found, %d caches", n)
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", sr.path, status)
+	}
+	if n := found[""]; n > 0 {
+		fmt.Fprintf(tw, "  --include-root\t%d folders\n", n)
+	}
+}
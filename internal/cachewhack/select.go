@@ -0,0 +1,100 @@
+package cachewhack
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSelection turns picker input into the 0-based indexes to whack out
+// of n candidates. Accepted forms:
+//
+//	"" or "all"       every folder
+//	"1,3,5-7"         only these folders
+//	"!2,4" or "-2,4"  every folder except these
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input == "" || input == "all" {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	except := strings.HasPrefix(input, "!") || strings.HasPrefix(input, "-")
+	if except {
+		input = input[1:]
+	}
+
+	picked := make([]bool, n)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		if from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", part, n)
+		}
+		for i := from; i <= to; i++ {
+			picked[i-1] = true
+		}
+	}
+
+	var out []int
+	for i, p := range picked {
+		if p != except {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+// pickTargets lists the candidates with their sizes and asks which to
+// whack. It returns nil if the user chose none or input ended.
+func pickTargets(scanner *bufio.Scanner, targets []string, sizes map[string]int64) []string {
+	fmt.Println()
+	for i, p := range targets {
+		size := "size unknown"
+		if noSize {
+			size = "size not computed"
+		} else if s, ok := sizes[p]; ok {
+			size = humanSize(s)
+		}
+		fmt.Printf("%3d) %s (%s)\n", i+1, p, size)
+	}
+
+	for {
+		fmt.Print("\nSelect folders: Enter = all, 1,3,5-7 = only these, !2,4 = all but these, q = quit: ")
+		if !scanner.Scan() {
+			return nil
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if strings.EqualFold(input, "q") {
+			return nil
+		}
+		idx, err := parseSelection(input, len(targets))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		selected := make([]string, 0, len(idx))
+		for _, i := range idx {
+			selected = append(selected, targets[i])
+		}
+		return selected
+	}
+}
@@ -0,0 +1,73 @@
+package cachewhack
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// checkStrictConfig validates --strict-config, under which only folders
+// the user listed are considered: --include-root directories as a whole,
+// and folders under --root whose names match a --pattern. The built-in
+// locations and name heuristics are not used at all.
+func checkStrictConfig() error {
+	for _, p := range strictPatterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid --pattern %q: %w", p, err)
+		}
+	}
+	if !strictConfig {
+		if len(strictPatterns) > 0 {
+			return fmt.Errorf("--pattern requires --strict-config")
+		}
+		return nil
+	}
+	if homeDir != "" {
+		return fmt.Errorf("--strict-config cannot be combined with --home, which uses the built-in locations")
+	}
+	if len(extraRoots) > 0 && len(strictPatterns) == 0 {
+		return fmt.Errorf("--strict-config: --root needs at least one --pattern to match folders by")
+	}
+	if len(includeRoots) == 0 && len(extraRoots) == 0 {
+		return fmt.Errorf("--strict-config needs --include-root paths, or --root with --pattern")
+	}
+	return nil
+}
+
+// strictPattern returns the first --pattern matching name, or "".
+func strictPattern(name string) string {
+	name = strings.ToLower(name)
+	for _, p := range strictPatterns {
+		if matched, _ := filepath.Match(strings.ToLower(p), name); matched {
+			return p
+		}
+	}
+	return ""
+}
+
+// writeStrictMatches reports which configured entries selected folders,
+// so an entry that matched nothing stands out.
+func writeStrictMatches(w io.Writer, folders []folder) {
+	included := make(map[string]bool, len(folders))
+	perPattern := make(map[string]int)
+	for _, f := range folders {
+		included[f.path] = true
+		if p := strictPattern(filepath.Base(f.path)); p != "" {
+			perPattern[p]++
+		}
+	}
+
+	fmt.Fprintln(w, "Configured entries:")
+	for _, r := range includeRoots {
+		abs, _ := filepath.Abs(r)
+		status := "included"
+		if !included[abs] {
+			status = "not listed (inside another --include-root)"
+		}
+		fmt.Fprintf(w, "  --include-root %s: %s\n", r, status)
+	}
+	for _, p := range strictPatterns {
+		fmt.Fprintf(w, "  --pattern %s: %d folders\n", p, perPattern[p])
+	}
+}
@@ -0,0 +1,65 @@
+package cachewhack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// runSummary is the --summary-json record of a run, for schedulers that
+// alert on the outcome rather than parse the console output.
+type runSummary struct {
+	FoldersFound   int            `json:"foldersFound"`
+	FoldersCleared int            `json:"foldersCleared"`
+	BytesFreed     int64          `json:"bytesFreed"` // 0 with --no-size
+	DryRun         bool           `json:"dryRun"`
+	Aborted        bool           `json:"aborted,omitempty"` // nothing selected or confirmation declined; nothing touched
+	Failures       []whackFailure `json:"failures"`
+}
+
+// whackFailure is a folder that could not be cleared.
+type whackFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// writeSummary writes s to --summary-json, if set.
+func writeSummary(s runSummary) error {
+	if summaryPath == "" {
+		return nil
+	}
+	if s.Failures == nil {
+		s.Failures = []whackFailure{}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(summaryPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing --summary-json: %w", err)
+	}
+	return nil
+}
+
+// abortRun writes s to --summary-json as an aborted run and returns err,
+// the reason input ended early, if any.
+func abortRun(s runSummary, err error) error {
+	s.Aborted = true
+	if werr := writeSummary(s); werr != nil && err == nil {
+		return werr
+	}
+	return err
+}
+
+// partialFailure is returned when a run cleared some folders but not all.
+// It exits with status 2, keeping 1 for runs that failed outright.
+type partialFailure struct {
+	failed int
+}
+
+func (e partialFailure) Error() string {
+	return fmt.Sprintf("%d cache folders could not be cleared", e.failed)
+}
+
+// ExitCode is picked up by main to set the process status.
+func (partialFailure) ExitCode() int { return 2 }
@@ -0,0 +1,48 @@
+package cachewhack
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// cleared reports whether a whacked folder stayed whacked: gone after a
+// delete, or still empty after --empty.
+func cleared(path string) bool {
+	if !empty {
+		_, err := os.Lstat(path)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	return errors.Is(err, io.EOF)
+}
+
+// verifyWhacked re-checks folders after whacking and returns those that
+// were recreated or not fully cleared, typically by a running application.
+// With retry, each such folder is whacked once more before giving up.
+func verifyWhacked(paths []string, retry bool) []string {
+	var resisted []string
+	for _, p := range paths {
+		if cleared(p) {
+			continue
+		}
+		if retry {
+			if empty {
+				_ = emptyDir(p)
+			} else {
+				_ = os.RemoveAll(p)
+			}
+			if cleared(p) {
+				continue
+			}
+		}
+		resisted = append(resisted, p)
+	}
+	return resisted
+}
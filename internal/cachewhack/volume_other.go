@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package cachewhack
+
+import "errors"
+
+// volumeOf is not supported here, so no free-space report is printed.
+func volumeOf(path string) (string, string, bool) {
+	return "", "", false
+}
+
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("free space not supported on this platform")
+}
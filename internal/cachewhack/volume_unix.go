@@ -0,0 +1,54 @@
+//go:build linux || darwin || freebsd
+
+package cachewhack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// volumeOf identifies the filesystem holding path by its device and labels
+// it with its mount point, found by walking up while the device stays the
+// same.
+func volumeOf(path string) (string, string, bool) {
+	dev, ok := deviceOf(path)
+	if !ok {
+		return "", "", false
+	}
+	mount := path
+	for {
+		parent := filepath.Dir(mount)
+		if parent == mount {
+			break
+		}
+		if d, ok := deviceOf(parent); !ok || d != dev {
+			break
+		}
+		mount = parent
+	}
+	return fmt.Sprint(dev), "Volume " + mount, true
+}
+
+func deviceOf(path string) (uint64, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// freeSpace returns the bytes available to unprivileged users on the
+// filesystem holding path.
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package cachewhack
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// volumeOf identifies the volume holding path by its drive letter or UNC
+// share.
+func volumeOf(path string) (string, string, bool) {
+	vol := filepath.VolumeName(path)
+	if vol == "" {
+		return "", "", false
+	}
+	return strings.ToUpper(vol), "Drive " + vol, true
+}
+
+// freeSpace returns the bytes available to the current user on the volume
+// holding path.
+func freeSpace(path string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var avail uint64
+	r, _, err := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&avail)), 0, 0)
+	if r == 0 {
+		return 0, err
+	}
+	return avail, nil
+}
@@ -0,0 +1,105 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// checkBulk refuses a run that would remove more than the configured share
+// or number of any cleanup tree's files. A plan that empties most of a tree
+// usually means --reference points at the wrong place.
+func checkBulk(cmd *cobra.Command, cfg *config, duplicates []duplicate, outFile *os.File) error {
+	maxFraction, _ := cmd.Flags().GetFloat64("max-delete-fraction")
+	maxCount, _ := cmd.Flags().GetInt("max-delete-count")
+	forceBulk, _ := cmd.Flags().GetBool("force-bulk")
+	if maxFraction < 0 || maxFraction > 1 {
+		return fmt.Errorf("--max-delete-fraction must be between 0 and 1")
+	}
+	if maxCount < 0 {
+		return fmt.Errorf("--max-delete-count must not be negative")
+	}
+	if maxFraction == 0 && maxCount == 0 {
+		return nil
+	}
+
+	removals := make(map[string]int)
+	for _, dup := range duplicates {
+		for _, f := range dup.cleanup {
+			removals[f.root]++
+		}
+	}
+	roots := make([]string, 0, len(removals))
+	for root := range removals {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	exceeded := 0
+	for _, root := range roots {
+		n, total := removals[root], cfg.treeFiles[root]
+		fraction := 0.0
+		if total > 0 {
+			fraction = float64(n) / float64(total)
+		}
+		if (maxFraction > 0 && fraction > maxFraction) || (maxCount > 0 && n > maxCount) {
+			exceeded++
+			output(outFile, fmt.Sprintf("\n⚠️  Would remove %d of %d files (%.1f%%) from %s", n, total, fraction*100, root))
+		}
+	}
+	if exceeded == 0 {
+		return nil
+	}
+	if forceBulk {
+		output(outFile, "Proceeding anyway because of --force-bulk.")
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%d cleanup trees exceed --max-delete-fraction/--max-delete-count; check --reference or pass --force-bulk", exceeded)
+}
+
+// addBulkFlags registers the bulk guard for commands that remove files.
+func addBulkFlags(c *cobra.Command) {
+	c.Flags().Float64("max-delete-fraction", 0, "refuse if more than this fraction (0-1) of any cleanup tree's files would be removed (0 = no limit)")
+	c.Flags().Int("max-delete-count", 0, "refuse if more than this many files would be removed from any cleanup tree (0 = no limit)")
+	c.Flags().Bool("force-bulk", false, "proceed even when --max-delete-fraction or --max-delete-count is exceeded")
+}
+
+// planBulkConfig prepares a plan's groups for checkBulk: each planned file
+// is assigned the cleanup tree it lies in and, when a guard is set, the
+// trees are counted as they are now, with the plan's hidden-file rule.
+func planBulkConfig(cmd *cobra.Command, p *plan, duplicates []duplicate) (*config, error) {
+	roots := make([]string, len(p.Cleanup))
+	for i, c := range p.Cleanup {
+		roots[i] = treeRoot(c)
+	}
+	for _, dup := range duplicates {
+		for _, f := range dup.cleanup {
+			for _, root := range roots {
+				if strings.HasPrefix(filepath.Clean(f.abs)+string(filepath.Separator), root) && len(root) > len(f.root) {
+					f.root = root
+				}
+			}
+		}
+	}
+
+	cfg := &config{cleanup: p.Cleanup}
+	maxFraction, _ := cmd.Flags().GetFloat64("max-delete-fraction")
+	maxCount, _ := cmd.Flags().GetInt("max-delete-count")
+	if maxFraction <= 0 && maxCount <= 0 {
+		return cfg, nil
+	}
+	scanned, _, err := scanTrees(p.Cleanup, p.IncludeHidden)
+	if err != nil {
+		return nil, err
+	}
+	cfg.treeFiles = make(map[string]int, len(roots))
+	for i, files := range scanned {
+		cfg.treeFiles[roots[i]] = len(files)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,76 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Values of --group-by.
+const (
+	groupByKey       = "key"
+	groupByReference = "reference"
+)
+
+// refEntry collects every group protected by one reference file.
+type refEntry struct {
+	reference *file
+	groups    []duplicate
+}
+
+// groupByRef merges the groups that share a reference file, ordered by
+// the reference's path.
+func groupByRef(duplicates []duplicate) []refEntry {
+	index := make(map[string]int)
+	var entries []refEntry
+	for _, dup := range duplicates {
+		key := filepath.Clean(dup.reference.abs)
+		i, ok := index[key]
+		if !ok {
+			i = len(entries)
+			index[key] = i
+			entries = append(entries, refEntry{reference: dup.reference})
+		}
+		entries[i].groups = append(entries[i].groups, dup)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].reference.abs < entries[j].reference.abs
+	})
+	return entries
+}
+
+// printByReference lists the duplicates per reference file for
+// --group-by reference: where the protected file lives and how big it is,
+// then each cleanup copy and what would happen to it.
+func printByReference(duplicates []duplicate, moveTo string, trash bool, outFile *os.File) {
+	for _, e := range groupByRef(duplicates) {
+		ref := e.reference
+		copies := 0
+		for _, dup := range e.groups {
+			copies += len(dup.cleanup) + len(dup.retained)
+			if dup.kept != nil {
+				copies++
+			}
+		}
+		output(outFile, fmt.Sprintf("\nReference: %s", ref.label()))
+		output(outFile, fmt.Sprintf("  Location: %s (tree %s)", filepath.Dir(ref.abs), filepath.Clean(ref.root)))
+		output(outFile, fmt.Sprintf("  Size: %d bytes", ref.size))
+		if copies == 1 {
+			output(outFile, "  1 copy in cleanup:")
+		} else {
+			output(outFile, fmt.Sprintf("  %d copies in cleanup:", copies))
+		}
+		for _, dup := range e.groups {
+			if dup.kept != nil {
+				output(outFile, fmt.Sprintf("    Keep: %s (survivor by --prefer `%s`)", dup.kept.label(), dup.rule))
+			}
+			for _, f := range dup.retained {
+				output(outFile, fmt.Sprintf("    Keep: %s (one per tree)", f.label()))
+			}
+			for _, f := range dup.cleanup {
+				output(outFile, "    "+cleanupLine(f, dup, moveTo, trash))
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// moveTarget hands out destination names inside a --move-to directory.
+// Duplicates from different folders often share a base name, and on a
+// case-insensitive filesystem Report.pdf and report.pdf are the same file,
+// so each name is checked against the disk and against the names already
+// handed out in this run, and suffixed " (2)", " (3)", ... until free.
+type moveTarget struct {
+	dir      string
+	foldCase bool // the target filesystem ignores case
+	claimed  map[string]bool
+}
+
+func newMoveTarget(dir string) *moveTarget {
+	return &moveTarget{dir: dir, foldCase: caseInsensitive(dir), claimed: make(map[string]bool)}
+}
+
+// dest returns a free destination for src and whether its name had to be
+// changed to avoid a collision.
+func (t *moveTarget) dest(src string) (string, bool) {
+	base := filepath.Base(src)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		}
+		key := name
+		if t.foldCase {
+			key = strings.ToLower(name)
+		}
+		if t.claimed[key] {
+			continue
+		}
+		dest := filepath.Join(t.dir, name)
+		if _, err := os.Lstat(dest); err == nil {
+			continue
+		}
+		t.claimed[key] = true
+		return dest, n > 1
+	}
+}
+
+// caseInsensitive reports whether dir's filesystem ignores case in names,
+// by creating a probe file and looking it up in upper case. If the probe
+// cannot be created, Windows and macOS are assumed case-insensitive.
+func caseInsensitive(dir string) bool {
+	f, err := os.CreateTemp(dir, ".dupekill-case-probe-*")
+	if err != nil {
+		return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+	}
+	probe := f.Name()
+	f.Close()
+	defer os.Remove(probe)
+
+	_, err = os.Lstat(filepath.Join(dir, strings.ToUpper(filepath.Base(probe))))
+	return err == nil
+}
@@ -0,0 +1,76 @@
+package dupekill
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// confirmPolicy decides how much typing a destructive run requires. Small
+// jobs take a plain y/yes; jobs above either threshold, or any job when a
+// phrase is configured, require the phrase typed exactly.
+type confirmPolicy struct {
+	assumeYes bool
+	phrase    string // custom phrase; empty means "DELETE" or "MOVE"
+	files     int    // strong confirmation above this many files (0 = never)
+	bytes     int64  // strong confirmation above this many bytes (0 = never)
+}
+
+// strongPhrase returns the phrase the user must type for this job, or ""
+// if a plain y/yes is enough.
+func (p confirmPolicy) strongPhrase(verb string, files int, bytes int64) string {
+	if p.phrase != "" {
+		return p.phrase
+	}
+	if (p.files > 0 && files > p.files) || (p.bytes > 0 && bytes > p.bytes) {
+		return strings.ToUpper(verb)
+	}
+	return ""
+}
+
+// confirm asks before verb-ing files. It returns false on any answer other
+// than the expected one, including end of input.
+func (p confirmPolicy) confirm(verb string, files int, bytes int64) bool {
+	if p.assumeYes {
+		return true
+	}
+	phrase := p.strongPhrase(verb, files, bytes)
+	if phrase == "" {
+		fmt.Printf("\nThis will %s %d files (%d bytes). Confirm (y/N): ", verb, files, bytes)
+	} else {
+		fmt.Printf("\nThis will %s %d files (%d bytes). Type %s to confirm: ", verb, files, bytes, phrase)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	answer := strings.TrimSpace(line)
+	if phrase != "" {
+		return answer == phrase
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// addConfirmFlags registers the confirmation flags for commands that act.
+func addConfirmFlags(c *cobra.Command) {
+	c.Flags().String("confirm-phrase", "", "require typing this exact phrase before acting, regardless of size")
+	c.Flags().Int("strong-confirm-files", 1000, "above this many files, require typing DELETE (or MOVE) instead of y (0 = never)")
+	c.Flags().Int64("strong-confirm-bytes", 10<<30, "above this many bytes, require typing DELETE (or MOVE) instead of y (0 = never)")
+}
+
+func parseConfirmPolicy(c *cobra.Command) (confirmPolicy, error) {
+	var p confirmPolicy
+	p.phrase, _ = c.Flags().GetString("confirm-phrase")
+	p.files, _ = c.Flags().GetInt("strong-confirm-files")
+	p.bytes, _ = c.Flags().GetInt64("strong-confirm-bytes")
+	if p.files < 0 || p.bytes < 0 {
+		return p, fmt.Errorf("--strong-confirm-files and --strong-confirm-bytes must not be negative")
+	}
+	p.phrase = strings.TrimSpace(p.phrase)
+	return p, nil
+}
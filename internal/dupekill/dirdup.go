@@ -0,0 +1,363 @@
+package dupekill
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirDuplicate is a cleanup directory whose every file has identical
+// content at the same relative path in a reference directory, and which
+// holds nothing else, so it can be removed as a unit.
+type dirDuplicate struct {
+	cleanup   string
+	reference string
+	files     []*file // the cleanup files it holds
+	size      int64
+}
+
+// dirOutcome is what happened to one duplicate directory.
+type dirOutcome struct {
+	Path      string `json:"path"`
+	Reference string `json:"reference"`
+	Files     int    `json:"files"`
+	Size      int64  `json:"size"`
+	Status    string `json:"status"`         // deleted | moved | trashed | failed | changed (left to per-file removal)
+	Dest      string `json:"dest,omitempty"` // moved: where the directory went
+	Error     string `json:"error,omitempty"`
+}
+
+// findDirDuplicates rolls the file-level groups up into whole directories
+// for --dir-level. A cleanup directory qualifies when a digest of its
+// (relative path, content hash) pairs equals that of a reference
+// directory, and every file in it is already slated for removal. Only the
+// topmost qualifying directories are kept; the cleanup roots themselves
+// never qualify. Their files are taken out of the returned file-level
+// groups, and groups left with nothing to remove are dropped.
+func findDirDuplicates(duplicates []duplicate, window hashWindow) ([]dirDuplicate, []duplicate) {
+	deletable := make(map[string]*file)
+	refOf := make(map[string]*file)
+	known := make(map[string]string) // abs path -> content hash
+	candidates := make(map[string]bool)
+	for _, dup := range duplicates {
+		if dup.reference.hash != "" {
+			known[dup.reference.abs] = dup.reference.hash
+		}
+		for _, f := range dup.cleanup {
+			if f.hash == "" || f.symlink {
+				continue
+			}
+			deletable[f.abs] = f
+			refOf[f.abs] = dup.reference
+			for rel := filepath.Dir(f.rel); rel != "." && rel != string(filepath.Separator); rel = filepath.Dir(rel) {
+				candidates[filepath.Join(f.root, rel)] = true
+			}
+		}
+	}
+
+	dirs := make([]string, 0, len(candidates))
+	for d := range candidates {
+		dirs = append(dirs, d)
+	}
+	// Shallowest first, so a qualifying parent claims its subdirectories
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], string(filepath.Separator)), strings.Count(dirs[j], string(filepath.Separator))
+		if di != dj {
+			return di < dj
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	var result []dirDuplicate
+	claimed := make(map[string]bool)
+	for _, d := range dirs {
+		if underAny(d, result) {
+			continue
+		}
+		files, digest, ok := cleanupDigest(d, deletable)
+		if !ok {
+			continue
+		}
+		for _, r := range referenceCandidates(d, files, refOf) {
+			if !sameTree(r, digest, known, window) {
+				continue
+			}
+			dd := dirDuplicate{cleanup: d, reference: r, files: files}
+			for _, f := range files {
+				dd.size += f.size
+				claimed[f.abs] = true
+			}
+			result = append(result, dd)
+			break
+		}
+	}
+	if len(result) == 0 {
+		return nil, duplicates
+	}
+
+	var remaining []duplicate
+	for _, dup := range duplicates {
+		var cleanup []*file
+		for _, f := range dup.cleanup {
+			if !claimed[f.abs] {
+				cleanup = append(cleanup, f)
+			}
+		}
+		if len(cleanup) > 0 {
+			dup.cleanup = cleanup
+			remaining = append(remaining, dup)
+		}
+	}
+	return result, remaining
+}
+
+// underAny reports whether dir lies inside a directory already found.
+func underAny(dir string, found []dirDuplicate) bool {
+	for _, d := range found {
+		if strings.HasPrefix(dir, d.cleanup+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupDigest walks dir on disk and digests its files. It fails if dir
+// holds anything that is not a regular file slated for removal, such as a
+// hidden or excluded file the scan skipped, or a symlink.
+func cleanupDigest(dir string, deletable map[string]*file) ([]*file, map[string]string, bool) {
+	var files []*file
+	digest := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		f, ok := deletable[path]
+		if !ok || !d.Type().IsRegular() {
+			return errNotDuplicateDir
+		}
+		rel, _ := filepath.Rel(dir, path)
+		digest[rel] = f.hash
+		files = append(files, f)
+		return nil
+	})
+	return files, digest, err == nil && len(files) > 0
+}
+
+var errNotDuplicateDir = errors.New("not a duplicate directory")
+
+// referenceCandidates derives the reference directories that could mirror
+// dir: for each file, the directory its reference copy sits in at the
+// same relative depth and name.
+func referenceCandidates(dir string, files []*file, refOf map[string]*file) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f.abs)
+		ref := refOf[f.abs]
+		suffix := string(filepath.Separator) + rel
+		if !strings.HasSuffix(ref.abs, suffix) {
+			continue
+		}
+		r := strings.TrimSuffix(ref.abs, suffix)
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// sameTree reports whether the reference directory r holds exactly the
+// files in digest, with the same content. Reference files not hashed
+// during analysis are hashed here.
+func sameTree(r string, digest map[string]string, known map[string]string, window hashWindow) bool {
+	matched := 0
+	err := filepath.WalkDir(r, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(r, path)
+		want, ok := digest[rel]
+		if !ok || !d.Type().IsRegular() {
+			return errNotDuplicateDir
+		}
+		h, ok := known[path]
+		if !ok {
+			var err error
+			if h, err = computeHash(path, window); err != nil {
+				return err
+			}
+		}
+		if h != want {
+			return errNotDuplicateDir
+		}
+		matched++
+		return nil
+	})
+	return err == nil && matched == len(digest)
+}
+
+// printDirDuplicates lists the duplicate directories and what would
+// happen to each.
+func printDirDuplicates(dirs []dirDuplicate, moveTo string, trash bool, outFile *os.File) {
+	action := "Delete"
+	if moveTo != "" {
+		action = "Move"
+	} else if trash {
+		action = "Trash"
+	}
+	files := 0
+	for _, d := range dirs {
+		files += len(d.files)
+	}
+	output(outFile, fmt.Sprintf("\nWould remove %d duplicate directories holding %d files", len(dirs), files))
+	for _, d := range dirs {
+		output(outFile, fmt.Sprintf("  %s: %s is a full duplicate of %s (%d files, %d bytes)", action, d.cleanup, d.reference, len(d.files), d.size))
+	}
+}
+
+// confirmDirLevel asks once for the directories and the remaining
+// file-level duplicates together.
+func confirmDirLevel(dirs []dirDuplicate, duplicates []duplicate, policy confirmPolicy, moveTo string, trash bool, outFile *os.File) bool {
+	files := 0
+	var bytes int64
+	for _, d := range dirs {
+		files += len(d.files)
+		bytes += d.size
+	}
+	for _, dup := range duplicates {
+		files += len(dup.cleanup)
+		for _, f := range dup.cleanup {
+			bytes += f.size
+		}
+	}
+	printDirDuplicates(dirs, moveTo, trash, outFile)
+	return policy.confirm(actionVerb(moveTo, trash), files, bytes)
+}
+
+// processDirDuplicates removes each duplicate directory as a whole and
+// returns the outcome of every directory. A directory is re-checked right
+// before it is removed; one that changed since the analysis is left alone
+// and its analyzed files are returned, to be removed one by one instead.
+func processDirDuplicates(dirs []dirDuplicate, moveTo string, trash bool, window hashWindow, outFile *os.File) ([]dirOutcome, map[*file]bool) {
+	var target *moveTarget
+	if moveTo != "" {
+		target = newMoveTarget(moveTo)
+	}
+	var outcomes []dirOutcome
+	fallback := make(map[*file]bool)
+	for _, d := range dirs {
+		outcome := dirOutcome{Path: d.cleanup, Reference: d.reference, Files: len(d.files), Size: d.size, Status: "deleted"}
+		if !unchangedDir(d, window) {
+			output(outFile, fmt.Sprintf("Directory %s changed since analysis; removing its duplicate files one by one", d.cleanup))
+			outcome.Status = "changed"
+			outcomes = append(outcomes, outcome)
+			for _, f := range d.files {
+				fallback[f] = true
+			}
+			continue
+		}
+		var err error
+		switch {
+		case moveTo != "":
+			dest, _ := target.dest(d.cleanup)
+			err = moveDir(d.cleanup, dest)
+			outcome.Status, outcome.Dest = "moved", dest
+		case trash:
+			err = trashFile(d.cleanup)
+			outcome.Status = "trashed"
+		default:
+			err = os.RemoveAll(d.cleanup)
+		}
+		if err != nil {
+			output(outFile, fmt.Sprintf("Failed to process directory %s: %v", d.cleanup, err))
+			outcome.Status, outcome.Dest, outcome.Error = "failed", "", err.Error()
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, fallback
+}
+
+// unchangedDir re-runs cleanupDigest on d and reports whether it still
+// holds exactly the analyzed files with the analyzed content, so a file
+// written into it since the scan, e.g. while the prompt waited, is never
+// removed unchecked.
+func unchangedDir(d dirDuplicate, window hashWindow) bool {
+	analyzed := make(map[string]*file, len(d.files))
+	for _, f := range d.files {
+		analyzed[f.abs] = f
+	}
+	files, digest, ok := cleanupDigest(d.cleanup, analyzed)
+	if !ok || len(files) != len(d.files) {
+		return false
+	}
+	for rel, want := range digest {
+		h, err := computeHash(filepath.Join(d.cleanup, rel), window)
+		if err != nil || h != want {
+			return false
+		}
+	}
+	return true
+}
+
+// regroup returns the groups of duplicates restricted to the given cleanup
+// files, dropping groups left with none.
+func regroup(duplicates []duplicate, files map[*file]bool) []duplicate {
+	var result []duplicate
+	for _, dup := range duplicates {
+		var cleanup []*file
+		for _, f := range dup.cleanup {
+			if files[f] {
+				cleanup = append(cleanup, f)
+			}
+		}
+		if len(cleanup) > 0 {
+			dup.cleanup = cleanup
+			result = append(result, dup)
+		}
+	}
+	return result
+}
+
+// actionVerb names what a run does to the cleanup copies.
+func actionVerb(moveTo string, trash bool) string {
+	if moveTo != "" {
+		return "move"
+	} else if trash {
+		return "trash"
+	}
+	return "delete"
+}
+
+// moveDir renames a directory to dst. Unlike moveFile it does not fall
+// back to copying, so a move across devices fails and leaves dir intact.
+func moveDir(dir, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("destination %s already exists", dst)
+	}
+	if err := os.Rename(dir, dst); err != nil {
+		return fmt.Errorf("directories are moved by rename only: %w", err)
+	}
+	return nil
+}
+
+// dirFailures counts the directories that could not be removed.
+func dirFailures(outcomes []dirOutcome) int {
+	n := 0
+	for _, o := range outcomes {
+		if o.Status == "failed" {
+			n++
+		}
+	}
+	return n
+}
@@ -1,17 +1,21 @@
 package dupekill
 
 import (
-	"bufio"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bryanbarcelona/data-symmetry/internal/move"
 	"github.com/spf13/cobra"
 )
 
@@ -22,38 +26,179 @@ const (
 	ModePathName Mode = "path+name"
 	ModePathHash Mode = "path+hash"
 	ModeHashOnly Mode = "hash"
+	// ModeHashName is hash mode with --require-name-match: the content and
+	// the base name must both match.
+	ModeHashName Mode = "hash+name"
 )
 
+// hashed reports whether the mode matches on content hashes.
+func (m Mode) hashed() bool {
+	return m == ModePathHash || m == ModeHashOnly || m == ModeHashName
+}
+
 type file struct {
-	root string
-	rel  string
-	abs  string
-	size int64
-	hash string
+	root    string
+	rel     string
+	abs     string
+	size    int64
+	modTime time.Time
+	id      linkID // device+inode on Unix; zero elsewhere
+	hash    string
+	symlink bool // size, modTime and id describe the link target
+}
+
+// label is the file's path annotated with its modification time.
+func (f *file) label() string {
+	if f.modTime.IsZero() {
+		return f.abs
+	}
+	return fmt.Sprintf("%s (modified %s)", f.abs, f.modTime.Local().Format("2006-01-02 15:04"))
+}
+
+// hashWindow trims a fixed number of bytes from the start and end of a file
+// before hashing, so files differing only in a header/footer compare equal.
+// With preview set it instead hashes only the size and that many bytes
+// from each end, a cheap fingerprint for --preview.
+type hashWindow struct {
+	head    int64
+	tail    int64
+	preview int64
+}
+
+// previewBytes is how much of each end of a file --preview reads.
+const previewBytes = 64 << 10
+
+func (w hashWindow) active() bool {
+	return w.head > 0 || w.tail > 0
 }
 
 type duplicate struct {
 	reference *file   // file in reference tree
 	cleanup   []*file // duplicates in cleanup trees
+	kept      *file   // cleanup file spared by a --prefer rule, if any
+	rule      string  // --prefer pattern that decided the survivor
+	master    string  // --master-rule that decided the survivor, if any
+	retained  []*file // cleanup files spared by --keep-one-per-tree
 }
 
-func scanTree(root string) ([]*file, error) {
+// selectSurvivors applies --prefer patterns in priority order to each group.
+// The first file (reference first, then cleanup files) whose base name
+// matches the highest-priority pattern survives. A surviving cleanup file is
+// removed from the delete list; the reference is never touched either way.
+func selectSurvivors(duplicates []duplicate, prefer []*regexp.Regexp) {
+	if len(prefer) == 0 {
+		return
+	}
+	for i := range duplicates {
+		dup := &duplicates[i]
+		candidates := append([]*file{dup.reference}, dup.cleanup...)
+
+	patterns:
+		for _, re := range prefer {
+			for _, f := range candidates {
+				if !re.MatchString(filepath.Base(f.abs)) {
+					continue
+				}
+				dup.rule = re.String()
+				if f != dup.reference {
+					dup.kept = f
+					remaining := make([]*file, 0, len(dup.cleanup)-1)
+					for _, c := range dup.cleanup {
+						if c != f {
+							remaining = append(remaining, c)
+						}
+					}
+					dup.cleanup = remaining
+				}
+				break patterns
+			}
+		}
+	}
+}
+
+// keepOnePerTree spares one file per cleanup tree in each group, so only
+// the extra copies within a tree are removed. The spared copy is the one
+// with the lexically smallest full path in that tree, unless the group's
+// --prefer survivor already lives there. Groups left with nothing to remove
+// are dropped.
+func keepOnePerTree(duplicates []duplicate) []duplicate {
+	var result []duplicate
+	for _, dup := range duplicates {
+		spared := make(map[string]bool)
+		if dup.kept != nil {
+			spared[dup.kept.root] = true
+		}
+		var remaining []*file
+		for _, f := range dup.cleanup { // sorted by abs path
+			if !spared[f.root] {
+				spared[f.root] = true
+				dup.retained = append(dup.retained, f)
+				continue
+			}
+			remaining = append(remaining, f)
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			result = append(result, dup)
+		}
+	}
+	return result
+}
+
+// scanDirLimit bounds concurrent directory reads within a single tree.
+const scanDirLimit = 16
+
+// scanStats counts what a scan left out.
+type scanStats struct {
+	hidden     int // hidden entries skipped without --include-hidden
+	unreadable int // subdirectories that could not be listed
+}
+
+func (s *scanStats) add(o scanStats) {
+	s.hidden += o.hidden
+	s.unreadable += o.unreadable
+}
+
+// report prints the counts that are not zero.
+func (s scanStats) report(outFile *os.File) {
+	if s.hidden > 0 {
+		output(outFile, fmt.Sprintf("Skipped %d hidden files and directories; use --include-hidden to include them", s.hidden))
+	}
+	if s.unreadable > 0 {
+		output(outFile, fmt.Sprintf("WARNING: %d directories could not be read; files in them were not considered", s.unreadable))
+	}
+}
+
+// scanTree lists the files under root. Unless includeHidden is set, hidden
+// files and directories are skipped. A root that cannot be read is an
+// error; unreadable subdirectories are only counted.
+func scanTree(root string, includeHidden bool) ([]*file, scanStats, error) {
 	var files []*file
+	var hidden, unreadable atomic.Int64
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	sem := make(chan struct{}, scanDirLimit)
 
-	if !strings.HasSuffix(root, string(filepath.Separator)) {
-		root += string(filepath.Separator)
+	root = treeRoot(root)
+	if _, err := os.ReadDir(root); err != nil {
+		return nil, scanStats{}, err
 	}
 
 	var scanDir func(string)
 	scanDir = func(current string) {
 		defer wg.Done()
+		sem <- struct{}{}
 		entries, err := os.ReadDir(current)
+		<-sem
 		if err != nil {
+			unreadable.Add(1)
 			return
 		}
 		for _, entry := range entries {
+			if !includeHidden && isHidden(entry) {
+				hidden.Add(1)
+				continue
+			}
 			fullPath := filepath.Join(current, entry.Name())
 			if entry.IsDir() {
 				wg.Add(1)
@@ -63,16 +208,27 @@ func scanTree(root string) ([]*file, error) {
 				if err != nil {
 					continue
 				}
+				symlink := entry.Type()&os.ModeSymlink != 0
+				if symlink {
+					// Describe the target; links to directories are never followed
+					info, err = os.Stat(fullPath)
+					if err != nil || info.IsDir() {
+						continue
+					}
+				}
 				rel, err := filepath.Rel(root, fullPath)
 				if err != nil {
 					continue
 				}
 				mu.Lock()
 				files = append(files, &file{
-					root: root,
-					rel:  rel,
-					abs:  fullPath,
-					size: info.Size(),
+					root:    root,
+					rel:     rel,
+					abs:     fullPath,
+					size:    info.Size(),
+					modTime: info.ModTime(),
+					id:      fileID(info),
+					symlink: symlink,
 				})
 				mu.Unlock()
 			}
@@ -82,27 +238,79 @@ func scanTree(root string) ([]*file, error) {
 	wg.Add(1)
 	scanDir(root)
 	wg.Wait()
-	return files, nil
+	sort.Slice(files, func(i, j int) bool { return files[i].abs < files[j].abs })
+	return files, scanStats{hidden: int(hidden.Load()), unreadable: int(unreadable.Load())}, nil
+}
+
+// treeRoot is root as recorded in file.root: with a trailing separator.
+func treeRoot(root string) string {
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return root
+}
+
+// scanTrees scans all roots concurrently and returns their files in the
+// same order as roots, plus their combined scan stats. The first error
+// encountered (by root order) wins.
+func scanTrees(roots []string, includeHidden bool) ([][]*file, scanStats, error) {
+	results := make([][]*file, len(roots))
+	stats := make([]scanStats, len(roots))
+	errs := make([]error, len(roots))
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(1)
+		go func(i int, root string) {
+			defer wg.Done()
+			results[i], stats[i], errs[i] = scanTree(root, includeHidden)
+		}(i, root)
+	}
+	wg.Wait()
+
+	var total scanStats
+	for i, err := range errs {
+		if err != nil {
+			return nil, scanStats{}, fmt.Errorf("scanning %s: %w", roots[i], err)
+		}
+		total.add(stats[i])
+	}
+	return results, total, nil
 }
 
-func hashFiles(files []*file) {
+// dropSymlinks removes symlinks from files and returns how many were dropped.
+func dropSymlinks(files []*file) ([]*file, int) {
+	kept := files[:0]
+	for _, f := range files {
+		if !f.symlink {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(files) - len(kept)
+}
+
+// hashFiles hashes files in place with the given number of workers. Files
+// that cannot be read keep an empty hash, so they match nothing; they are
+// returned so callers can say which duplicates went undetected.
+func hashFiles(files []*file, window hashWindow, workers int) []hashFailure {
 	type job struct {
 		index int
 		file  *file
 	}
+	type result struct {
+		index int
+		hash  string
+		err   error
+	}
 
 	if len(files) == 0 {
-		return
+		return nil
 	}
 
 	jobs := make(chan job, len(files))
-	results := make(chan struct {
-		index int
-		hash  string
-	}, len(files))
+	results := make(chan result, len(files))
 
 	var wg sync.WaitGroup
-	numWorkers := 32
+	numWorkers := workers
 	if len(files) < numWorkers {
 		numWorkers = len(files)
 	}
@@ -112,13 +320,8 @@ func hashFiles(files []*file) {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				hash, err := computeHash(job.file.abs)
-				if err == nil {
-					results <- struct {
-						index int
-						hash  string
-					}{job.index, hash}
-				}
+				hash, err := computeHash(job.file.abs, window)
+				results <- result{job.index, hash, err}
 			}
 		}()
 	}
@@ -135,112 +338,201 @@ func hashFiles(files []*file) {
 		close(results)
 	}()
 
-	for result := range results {
-		files[result.index].hash = result.hash
+	var failed []hashFailure
+	for r := range results {
+		if r.err != nil {
+			failed = append(failed, hashFailure{path: files[r.index].abs, err: r.err})
+			continue
+		}
+		files[r.index].hash = r.hash
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].path < failed[j].path })
+	return failed
+}
+
+// hashFailure is a file hashFiles could not read.
+type hashFailure struct {
+	path string
+	err  error
+}
+
+// defaultHashWorkers is the --hash-workers default.
+const defaultHashWorkers = 32
+
+// reportHashFailures warns that files could not be hashed and lists them.
+// An unreadable reference file no longer protects its copies, and an
+// unreadable cleanup file is simply never matched.
+func reportHashFailures(failed []hashFailure, out *os.File) {
+	if len(failed) == 0 {
+		return
+	}
+	output(out, fmt.Sprintf("WARNING: %d files could not be hashed; their duplicates were not detected:", len(failed)))
+	for _, f := range failed {
+		output(out, fmt.Sprintf("  %s: %v", f.path, f.err))
 	}
 }
 
-func computeHash(path string) (string, error) {
+func computeHash(path string, window hashWindow) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
+	if window.preview > 0 {
+		return fingerprint(f, h, window.preview)
+	}
+	if !window.active() {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	// Files too small to have a middle portion are never treated as equal
+	n := info.Size() - window.head - window.tail
+	if n <= 0 {
+		return "", fmt.Errorf("%s: file smaller than skipped head/tail bytes", path)
+	}
+	if _, err := f.Seek(window.head, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(h, f, n); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func findDuplicates(referenceFiles, cleanupFiles []*file, mode Mode, out *os.File) []duplicate {
+// fingerprint hashes f's size and its first and last n bytes, or the whole
+// file when it is no larger than 2n. Equal fingerprints make files likely,
+// not proven, duplicates.
+func fingerprint(f *os.File, h hash.Hash, n int64) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+	fmt.Fprintf(h, "%d:", size)
+	if size <= 2*n {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(size-n, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// findDuplicates returns every duplicate group, sorted by reference path.
+func findDuplicates(referenceFiles, cleanupFiles []*file, mode Mode, window hashWindow, workers int, progressive bool, out *os.File) []duplicate {
+	var result []duplicate
+	forEachDuplicate(referenceFiles, cleanupFiles, mode, window, workers, progressive, out, func(dup duplicate) {
+		result = append(result, dup)
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].reference.abs < result[j].reference.abs
+	})
+
+	fmt.Fprintf(out, "Found %d duplicate groups\n", len(result))
+	return result
+}
+
+// forEachDuplicate calls fn for each duplicate group as soon as it is
+// known, in reference-file order, without collecting the groups. Cleanup
+// files within a group are sorted by path. With progressive, hash modes
+// only hash files that could still have a match (see progressiveHash).
+func forEachDuplicate(referenceFiles, cleanupFiles []*file, mode Mode, window hashWindow, workers int, progressive bool, out *os.File, fn func(duplicate)) {
 	fmt.Fprintf(out, "Finding duplicates using %s mode...\n", mode)
 
 	// Hash files if needed for hash-based modes
-	if mode == ModePathHash || mode == ModeHashOnly {
-		fmt.Fprintln(out, "Computing file hashes...")
-		hashFiles(referenceFiles)
-		hashFiles(cleanupFiles)
+	if mode.hashed() {
+		if window.preview > 0 {
+			fmt.Fprintf(out, "Computing fingerprints (UNVERIFIED preview: size plus first and last %d KB)...\n", window.preview>>10)
+		} else if window.active() {
+			fmt.Fprintf(out, "Computing file hashes (UNVERIFIED: ignoring first %d and last %d bytes)...\n", window.head, window.tail)
+		} else {
+			fmt.Fprintln(out, "Computing file hashes...")
+		}
+		var failed []hashFailure
+		// Reference files loaded from a manifest arrive hashed
+		if len(referenceFiles) > 0 && referenceFiles[0].hash != "" {
+			failed = hashAgainstReference(referenceFiles, cleanupFiles, mode, workers, out)
+		} else if progressive {
+			failed = progressiveHash(referenceFiles, cleanupFiles, mode, window, workers, out)
+		} else {
+			failed = hashFiles(referenceFiles, window, workers)
+			failed = append(failed, hashFiles(cleanupFiles, window, workers)...)
+		}
+		reportHashFailures(failed, out)
 	}
 
 	// Build reference index
 	referenceIndex := make(map[string]*file)
-	switch mode {
-	case ModePathOnly: // NEW CASE
-		for _, f := range referenceFiles {
-			// Pure path-only matching: just the relative path
-			referenceIndex[f.rel] = f
-		}
-	case ModePathName:
-		for _, f := range referenceFiles {
-			// For path+name, include size in the key to ensure exact match
-			key := f.rel + "|" + fmt.Sprintf("%d", f.size)
+	for _, f := range referenceFiles {
+		if key := dupKey(f, mode); key != "" {
 			referenceIndex[key] = f
 		}
-	case ModePathHash:
-		for _, f := range referenceFiles {
-			if f.hash != "" {
-				referenceIndex[f.rel+"|"+f.hash] = f
-			}
-		}
-	case ModeHashOnly:
-		for _, f := range referenceFiles {
-			if f.hash != "" {
-				referenceIndex[f.hash] = f
-			}
-		}
 	}
 
-	// Find duplicates in cleanup trees
-	duplicates := make(map[string]*duplicate)
-
+	// Index cleanup files by the same key
+	cleanupIndex := make(map[string][]*file)
 	for _, cleanupFile := range cleanupFiles {
-		var key string
-		switch mode {
-		case ModePathOnly:
-			key = cleanupFile.rel
-		case ModePathName:
-			// Include size in the key for exact matching
-			key = cleanupFile.rel + "|" + fmt.Sprintf("%d", cleanupFile.size)
-		case ModePathHash:
-			if cleanupFile.hash != "" {
-				key = cleanupFile.rel + "|" + cleanupFile.hash
-			}
-		case ModeHashOnly:
-			if cleanupFile.hash != "" {
-				key = cleanupFile.hash
-			}
-		}
-
-		if key != "" {
-			if refFile, exists := referenceIndex[key]; exists {
-				if dup, exists := duplicates[key]; exists {
-					dup.cleanup = append(dup.cleanup, cleanupFile)
-				} else {
-					duplicates[key] = &duplicate{
-						reference: refFile,
-						cleanup:   []*file{cleanupFile},
-					}
-				}
+		if key := dupKey(cleanupFile, mode); key != "" {
+			if _, exists := referenceIndex[key]; exists {
+				cleanupIndex[key] = append(cleanupIndex[key], cleanupFile)
 			}
 		}
 	}
 
-	// Convert to slice
-	var result []duplicate
-	for _, dup := range duplicates {
-		sort.Slice(dup.cleanup, func(i, j int) bool {
-			return dup.cleanup[i].abs < dup.cleanup[j].abs
+	// Walk the reference files so each group is emitted once, complete
+	for _, refFile := range referenceFiles {
+		key := dupKey(refFile, mode)
+		if key == "" || referenceIndex[key] != refFile || len(cleanupIndex[key]) == 0 {
+			continue
+		}
+		matches := cleanupIndex[key]
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].abs < matches[j].abs
 		})
-		result = append(result, *dup)
+		delete(cleanupIndex, key)
+		fn(duplicate{reference: refFile, cleanup: matches})
 	}
+}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].reference.abs < result[j].reference.abs
-	})
-
-	fmt.Fprintf(out, "Found %d duplicate groups\n", len(result))
-	return result
+// dupKey is the matching key of f in the given mode, or "" if f cannot be
+// matched (e.g. it could not be hashed).
+func dupKey(f *file, mode Mode) string {
+	switch mode {
+	case ModePathOnly:
+		return f.rel
+	case ModePathName:
+		// Include size in the key for exact matching
+		return f.rel + "|" + fmt.Sprintf("%d", f.size)
+	case ModePathHash:
+		if f.hash != "" {
+			return f.rel + "|" + f.hash
+		}
+	case ModeHashOnly:
+		return f.hash
+	case ModeHashName:
+		if f.hash != "" {
+			return f.hash + "|" + filepath.Base(f.rel)
+		}
+	}
+	return ""
 }
 
 func output(outFile *os.File, s string) {
@@ -251,202 +543,801 @@ func output(outFile *os.File, s string) {
 	}
 }
 
-func processDuplicates(duplicates []duplicate, dryRun bool, delete bool, moveTo string, outFile *os.File) error {
+// processDuplicates lists the duplicates and, unless dryRun, removes the
+// cleanup copies: moved to moveTo, sent to the OS trash with trash, or
+// deleted outright. A real run returns the outcome of every file, also when
+// some operations failed; a dry run returns nil.
+func processDuplicates(duplicates []duplicate, dryRun bool, delete bool, policy confirmPolicy, moveTo string, trash bool, verifyMoveHash bool, outFile *os.File) (*runSummary, error) {
 	totalDupes := 0
+	var totalBytes int64
 	for _, dup := range duplicates {
 		totalDupes += len(dup.cleanup)
+		for _, f := range dup.cleanup {
+			totalBytes += f.size
+		}
 	}
 
 	output(outFile, fmt.Sprintf("\nWould remove %d duplicate files across %d groups", totalDupes, len(duplicates)))
 
 	if dryRun || !delete {
 		for i, dup := range duplicates {
-			output(outFile, fmt.Sprintf("\nGroup %d:", i+1))
-			output(outFile, fmt.Sprintf("  Reference: %s", dup.reference.abs))
-			for _, f := range dup.cleanup {
-				action := "Delete"
-				if moveTo != "" {
-					action = "Move"
-				}
-				output(outFile, fmt.Sprintf("  %s: %s", action, f.abs))
-			}
+			printGroup(i+1, dup, moveTo, trash, outFile)
 		}
 		output(outFile, "\nDry-run enabled. No files affected.")
-		return nil
+		return nil, nil
+	}
+
+	verb := "delete"
+	if moveTo != "" {
+		verb = "move"
+	} else if trash {
+		verb = "trash"
 	}
+	summary := &runSummary{Action: verb, MoveTo: moveTo, Groups: len(duplicates), FilesPlanned: totalDupes}
 
-	fmt.Printf("\nThis will %s %d files. Confirm (y/N): ",
-		map[bool]string{true: "delete", false: "move"}[moveTo == ""], totalDupes)
+	// Files another program holds open would fail mid-run; set them aside
+	// up front so the pass only attempts what it can finish
+	busy := make(map[*file]bool)
+	for _, dup := range duplicates {
+		for _, f := range dup.cleanup {
+			if inUse(f.abs) {
+				busy[f] = true
+				output(outFile, fmt.Sprintf("In use, skipped: %s", f.abs))
+				totalDupes--
+				totalBytes -= f.size
+			}
+		}
+	}
+	if len(busy) > 0 && totalDupes == 0 {
+		output(outFile, "Every duplicate is in use by another program; nothing to do.")
+	}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() || strings.ToLower(strings.TrimSpace(scanner.Text())) != "y" {
+	if totalDupes > 0 && !policy.confirm(verb, totalDupes, totalBytes) {
 		output(outFile, "Aborted.")
-		return nil
+		summary.Aborted = true
+		summary.Finished = time.Now()
+		return summary, nil
+	}
+
+	var all []*file
+	for _, dup := range duplicates {
+		all = append(all, dup.cleanup...)
+	}
+	states := statLinks(all)
+
+	var target *moveTarget
+	if moveTo != "" {
+		target = newMoveTarget(moveTo)
 	}
 
-	var failed int
+	var failed, leftInPlace, renamed int
+	skipped := len(busy)
+	var removed []*file
 	for _, dup := range duplicates {
+		group := groupOutcome{Reference: dup.reference.abs}
 		for _, f := range dup.cleanup {
 			var err error
+			outcome := fileOutcome{Path: f.abs, Size: f.size, Status: "deleted"}
+			if busy[f] {
+				outcome.Status = "in_use"
+				group.Files = append(group.Files, outcome)
+				continue
+			}
 			if moveTo != "" {
-				dest := filepath.Join(moveTo, filepath.Base(f.abs))
-				err = os.Rename(f.abs, dest)
+				dest, collided := target.dest(f.abs)
+				err = moveFile(f.abs, dest, verifyMoveHash)
+				outcome.Status, outcome.Dest = "moved", dest
+				if err == nil && collided {
+					output(outFile, fmt.Sprintf("Renamed to avoid a name collision: %s -> %s", f.abs, dest))
+					renamed++
+				}
+			} else if trash {
+				err = trashFile(f.abs)
+				outcome.Status = "trashed"
 			} else {
 				err = os.Remove(f.abs)
 			}
 
-			if err != nil {
+			if isInUseErr(err) {
+				output(outFile, fmt.Sprintf("In use, skipped: %s", f.abs))
+				skipped++
+				outcome.Status, outcome.Dest, outcome.Error = "in_use", "", err.Error()
+			} else if errors.Is(err, move.ErrUnverified) {
+				output(outFile, fmt.Sprintf("Left in place %s: %v", f.abs, err))
+				leftInPlace++
+				failed++
+				outcome.Status, outcome.Dest, outcome.Error = "left_in_place", "", err.Error()
+			} else if err != nil {
 				output(outFile, fmt.Sprintf("Failed to process %s: %v", f.abs, err))
 				failed++
+				outcome.Status, outcome.Dest, outcome.Error = "failed", "", err.Error()
+			} else {
+				removed = append(removed, f)
 			}
+			group.Files = append(group.Files, outcome)
+		}
+		summary.GroupOutcomes = append(summary.GroupOutcomes, group)
+	}
+
+	nominal, actual := spaceFreed(removed, states)
+	summary.FilesRemoved, summary.FilesFailed, summary.FilesRenamed, summary.FilesInUse = len(removed), failed, renamed, skipped
+	summary.BytesRemoved = nominal
+	if !trash && moveTo == "" && hardlinksSupported {
+		summary.BytesFreed = &actual
+	}
+	summary.Finished = time.Now()
+
+	if trash {
+		output(outFile, "Trashed files still use disk space until the trash is emptied")
+	} else if moveTo == "" {
+		output(outFile, fmt.Sprintf("Nominal size removed: %d bytes", nominal))
+		if hardlinksSupported {
+			output(outFile, fmt.Sprintf("Actual space freed:   %d bytes", actual))
+		} else {
+			output(outFile, "Actual space freed:   not measurable on this platform (hardlinks not detected; assume nominal)")
 		}
 	}
 
+	if renamed > 0 {
+		output(outFile, fmt.Sprintf("%d moved files were renamed because their name was already taken in %s", renamed, moveTo))
+	}
+	if skipped > 0 {
+		output(outFile, fmt.Sprintf("%d files were in use by another program and skipped; close it and re-run to remove them", skipped))
+	}
+	if leftInPlace > 0 {
+		output(outFile, fmt.Sprintf("%d files left in place because their copy could not be verified", leftInPlace))
+	}
 	if failed > 0 {
-		return fmt.Errorf("%d operations failed", failed)
+		return summary, fmt.Errorf("%d operations failed", failed)
 	}
 
-	output(outFile, fmt.Sprintf("Successfully processed %d duplicate files", totalDupes))
-	return nil
+	output(outFile, fmt.Sprintf("Successfully processed %d duplicate files", len(removed)))
+	return summary, nil
+}
+
+// printGroup lists one duplicate group and what would happen to each file.
+func printGroup(n int, dup duplicate, moveTo string, trash bool, outFile *os.File) {
+	output(outFile, fmt.Sprintf("\nGroup %d:", n))
+	if dup.master != "" {
+		output(outFile, fmt.Sprintf("  Reference: %s (survivor by --master-rule %s)", dup.reference.label(), dup.master))
+	} else if dup.rule != "" && dup.kept == nil {
+		output(outFile, fmt.Sprintf("  Reference: %s (survivor by --prefer `%s`)", dup.reference.label(), dup.rule))
+	} else {
+		output(outFile, fmt.Sprintf("  Reference: %s", dup.reference.label()))
+	}
+	if dup.kept != nil {
+		output(outFile, fmt.Sprintf("  Keep: %s (survivor by --prefer `%s`)", dup.kept.label(), dup.rule))
+	}
+	for _, f := range dup.retained {
+		output(outFile, fmt.Sprintf("  Keep: %s (one per tree)", f.label()))
+	}
+	for _, f := range dup.cleanup {
+		output(outFile, "  "+cleanupLine(f, dup, moveTo, trash))
+	}
 }
 
-// removeEmptyDirs recursively removes empty directories
-func removeEmptyDirs(roots []string, dryRun bool, outFile *os.File) {
+// cleanupLine describes what would happen to cleanup file f of dup.
+func cleanupLine(f *file, dup duplicate, moveTo string, trash bool) string {
+	action := "Delete"
+	if moveTo != "" {
+		action = "Move"
+	} else if trash {
+		action = "Trash"
+	}
+	if len(dup.retained) > 0 {
+		action += " extra"
+	}
+	if f.symlink {
+		action += " symlink"
+	}
+	line := fmt.Sprintf("%s: %s", action, f.label())
+	if newerThanReference(f, dup) {
+		line += " (newer than reference)"
+	}
+	return line
+}
+
+// removeEmptyDirs recursively removes empty directories. With dryRun it
+// only reports the directories that would be empty once the files in gone
+// (the planned removals) are deleted; gone is ignored otherwise.
+func removeEmptyDirs(roots []string, gone map[string]bool, dryRun bool, outFile *os.File) {
 	for _, root := range roots {
+		if dryRun {
+			output(outFile, fmt.Sprintf("Empty directories after cleanup in: %s", root))
+			removed, _ := removeEmptyDirsRecursive(root, gone, dryRun, outFile)
+			output(outFile, fmt.Sprintf("Would remove %d empty directories", removed))
+			continue
+		}
 		output(outFile, fmt.Sprintf("Cleaning empty directories in: %s", root))
-		removed := removeEmptyDirsRecursive(root, dryRun, outFile)
+		removed, _ := removeEmptyDirsRecursive(root, nil, dryRun, outFile)
 		output(outFile, fmt.Sprintf("Removed %d empty directories", removed))
 	}
 }
 
-// removeEmptyDirsRecursive does the actual work and returns count of removed dirs
-func removeEmptyDirsRecursive(dir string, dryRun bool, outFile *os.File) int {
+// plannedRemovals is the set of cleanup paths processDuplicates would
+// remove, for previewing the empty directories they leave behind.
+func plannedRemovals(duplicates []duplicate) map[string]bool {
+	gone := make(map[string]bool)
+	for _, dup := range duplicates {
+		for _, f := range dup.cleanup {
+			gone[filepath.Clean(f.abs)] = true
+		}
+	}
+	return gone
+}
+
+// removeEmptyDirsRecursive does the actual work and returns count of removed
+// dirs and whether dir itself was (or, with dryRun, would be) removed
+func removeEmptyDirsRecursive(dir string, gone map[string]bool, dryRun bool, outFile *os.File) (int, bool) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return 0
+		return 0, false
 	}
 
 	// First, recursively process subdirectories
 	removedCount := 0
+	remaining := 0
 	for _, entry := range entries {
+		fullPath := filepath.Join(dir, entry.Name())
 		if entry.IsDir() {
-			fullPath := filepath.Join(dir, entry.Name())
-			removedCount += removeEmptyDirsRecursive(fullPath, dryRun, outFile)
+			n, removed := removeEmptyDirsRecursive(fullPath, gone, dryRun, outFile)
+			removedCount += n
+			if !removed {
+				remaining++
+			}
+		} else if !gone[fullPath] {
+			remaining++
+		}
+	}
+
+	if dryRun {
+		if remaining > 0 || dir == "" {
+			return removedCount, false
 		}
+		output(outFile, fmt.Sprintf("  Would remove empty directory: %s", dir))
+		return removedCount + 1, true
 	}
 
 	// Re-read directory to see if it's now empty (after processing subdirs)
 	entries, err = os.ReadDir(dir)
 	if err != nil {
-		return removedCount
+		return removedCount, false
 	}
 
 	// If directory is empty (and not the root of our cleanup trees), remove it
 	if len(entries) == 0 && dir != "" {
-		if dryRun {
-			output(outFile, fmt.Sprintf("  Would remove empty directory: %s", dir))
-		} else {
-			if err := os.Remove(dir); err == nil {
-				output(outFile, fmt.Sprintf("  Removed empty directory: %s", dir))
-				removedCount++
-			}
+		if err := os.Remove(dir); err == nil {
+			output(outFile, fmt.Sprintf("  Removed empty directory: %s", dir))
+			return removedCount + 1, true
 		}
 	}
 
-	return removedCount
+	return removedCount, false
 }
 
-func run(cmd *cobra.Command, args []string) error {
-	reference, _ := cmd.Flags().GetString("reference")
-	cleanup, _ := cmd.Flags().GetStringSlice("cleanup")
+// config holds the scan/match settings shared by run and plan.
+type config struct {
+	reference           string
+	cleanup             []string
+	mode                Mode
+	moveTo              string
+	trash               bool // send duplicates to the OS recycle bin instead of deleting
+	outPath             string
+	keepEmptyDirs       bool
+	window              hashWindow
+	forceUnverified     bool
+	prefer              []*regexp.Regexp
+	followSymlinks      bool
+	verifyMoveHash      bool
+	keepOnePerTree      bool
+	self                bool // reference is also the only cleanup tree
+	stream              bool // emit groups as they are found instead of collecting them
+	confirm             confirmPolicy
+	treeFiles           map[string]int // files found per cleanup tree, keyed by treeRoot
+	includeHidden       bool
+	hashWorkers         int
+	allowEmptyReference bool
+	neverDeleteExts     []string // extensions never removed, lower case with a leading dot
+	skipNewer           bool     // keep cleanup files modified after their reference
+	masterRules         []string // --master-rule names, in priority order
+	noProgressive       bool     // hash every file in full instead of pruning by size and partial hash first
+	referenceManifest   string   // manifest standing in for the reference tree
+	manifestFiles       []*file  // reference files loaded from referenceManifest, hashed
+	manifestSample      float64  // percent of relied-on manifest entries to re-hash when the archive is reachable
+	manifestStale       bool     // a sampled manifest entry disagreed with the archive
+	byReference         bool     // list duplicates per reference file (--group-by reference)
+	referenceFiles      []*file  // reference files as analyzed, for --export-reference-manifest
+}
+
+func parseConfig(cmd *cobra.Command) (*config, error) {
+	cfg := &config{}
+	cfg.reference, _ = cmd.Flags().GetString("reference")
+	cfg.referenceManifest, _ = cmd.Flags().GetString("reference-manifest")
+	cfg.manifestSample, _ = cmd.Flags().GetFloat64("verify-manifest-sample")
+	cfg.cleanup, _ = cmd.Flags().GetStringSlice("cleanup")
 	modeStr, _ := cmd.Flags().GetString("mode")
-	moveTo, _ := cmd.Flags().GetString("move-to")
-	outPath, _ := cmd.Flags().GetString("out")
-	keepEmptyDirs, _ := cmd.Flags().GetBool("keep-empty-dirs")
+	cfg.moveTo, _ = cmd.Flags().GetString("move-to")
+	cfg.trash, _ = cmd.Flags().GetBool("trash")
+	cfg.outPath, _ = cmd.Flags().GetString("out")
+	cfg.keepEmptyDirs, _ = cmd.Flags().GetBool("keep-empty-dirs")
+	skipHead, _ := cmd.Flags().GetInt64("skip-head-bytes")
+	skipTail, _ := cmd.Flags().GetInt64("skip-tail-bytes")
+	cfg.forceUnverified, _ = cmd.Flags().GetBool("force-unverified")
+	preferStrs, _ := cmd.Flags().GetStringArray("prefer")
+	cfg.followSymlinks, _ = cmd.Flags().GetBool("follow-symlinks")
+	cfg.verifyMoveHash, _ = cmd.Flags().GetBool("verify-move-hash")
+	cfg.keepOnePerTree, _ = cmd.Flags().GetBool("keep-one-per-tree")
+	cfg.self, _ = cmd.Flags().GetBool("exclude-reference-self")
+	cfg.noProgressive, _ = cmd.Flags().GetBool("no-progressive")
+	masterRules, _ := cmd.Flags().GetStringSlice("master-rule")
+	cfg.stream, _ = cmd.Flags().GetBool("stream")
+	cfg.includeHidden, _ = cmd.Flags().GetBool("include-hidden")
+	cfg.hashWorkers, _ = cmd.Flags().GetInt("hash-workers")
+	cfg.allowEmptyReference, _ = cmd.Flags().GetBool("allow-empty-reference")
+	neverDeleteExts, _ := cmd.Flags().GetStringArray("never-delete-ext")
+	cfg.skipNewer, _ = cmd.Flags().GetBool("skip-newer-than-reference")
 
-	mode := Mode(modeStr)
-	if mode != ModePathOnly && mode != ModePathName && mode != ModePathHash && mode != ModeHashOnly {
-		return fmt.Errorf("invalid mode: %s (use: path, path+name, path+hash, hash)", modeStr)
+	cfg.mode = Mode(modeStr)
+	if cfg.mode != ModePathOnly && cfg.mode != ModePathName && cfg.mode != ModePathHash && cfg.mode != ModeHashOnly {
+		return nil, fmt.Errorf("invalid mode: %s (use: path, path+name, path+hash, hash)", modeStr)
+	}
+	if requireName, _ := cmd.Flags().GetBool("require-name-match"); requireName {
+		if cfg.mode != ModeHashOnly {
+			return nil, fmt.Errorf("--require-name-match applies to --mode hash only (path modes already match on the path)")
+		}
+		cfg.mode = ModeHashName
 	}
 
-	if len(cleanup) == 0 {
-		return fmt.Errorf("at least one cleanup directory required")
+	if skipHead < 0 || skipTail < 0 {
+		return nil, fmt.Errorf("--skip-head-bytes and --skip-tail-bytes must not be negative")
+	}
+	cfg.window = hashWindow{head: skipHead, tail: skipTail}
+	if preview, _ := cmd.Flags().GetBool("preview"); preview {
+		if cfg.window.active() || !cfg.mode.hashed() {
+			return nil, fmt.Errorf("--preview requires a hash mode (path+hash or hash) and cannot be combined with --skip-head-bytes/--skip-tail-bytes")
+		}
+		cfg.window.preview = previewBytes
+	}
+	if cfg.window.active() && !cfg.mode.hashed() {
+		return nil, fmt.Errorf("--skip-head-bytes/--skip-tail-bytes require a hash mode (path+hash or hash)")
 	}
 
-	var outFile *os.File
-	if outPath != "" {
-		var err error
-		outFile, err = os.Create(outPath)
+	if cfg.manifestSample < 0 || cfg.manifestSample > 100 {
+		return nil, fmt.Errorf("--verify-manifest-sample must be a percentage from 0 to 100")
+	}
+	if cfg.manifestSample > 0 && cfg.referenceManifest == "" {
+		return nil, fmt.Errorf("--verify-manifest-sample requires --reference-manifest")
+	}
+	if cfg.referenceManifest != "" {
+		if cfg.window.active() || cfg.window.preview > 0 {
+			return nil, fmt.Errorf("--reference-manifest holds full-file hashes and cannot be combined with --preview or --skip-head-bytes/--skip-tail-bytes")
+		}
+		if cfg.self {
+			return nil, fmt.Errorf("--reference-manifest cannot be combined with --exclude-reference-self")
+		}
+		m, files, err := loadReferenceManifest(cfg.referenceManifest)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		defer outFile.Close()
+		cfg.reference, cfg.manifestFiles = m.Root, files
+	}
+
+	if len(cfg.cleanup) == 0 {
+		return nil, fmt.Errorf("at least one cleanup directory required")
+	}
+	if cfg.hashWorkers <= 0 {
+		return nil, fmt.Errorf("--hash-workers must be positive")
+	}
+
+	if cfg.trash {
+		if cfg.moveTo != "" {
+			return nil, fmt.Errorf("--trash cannot be combined with --move-to")
+		}
+		if !trashSupported {
+			return nil, errTrashUnsupported
+		}
+	}
+
+	if cfg.stream && cfg.self {
+		return nil, fmt.Errorf("--stream cannot be combined with --exclude-reference-self")
+	}
+
+	if cfg.self {
+		if len(cfg.cleanup) != 1 || !sameDir(cfg.cleanup[0], cfg.reference) {
+			return nil, fmt.Errorf("--exclude-reference-self requires --cleanup to be the same single directory as --reference")
+		}
+		if cfg.mode == ModeHashName {
+			return nil, fmt.Errorf("--require-name-match cannot be combined with --exclude-reference-self")
+		}
+		if cfg.mode != ModeHashOnly {
+			return nil, fmt.Errorf("--exclude-reference-self requires --mode hash")
+		}
+		if cfg.keepOnePerTree {
+			return nil, fmt.Errorf("--keep-one-per-tree cannot be combined with --exclude-reference-self")
+		}
+	} else {
+		for _, c := range cfg.cleanup {
+			if sameDir(c, cfg.reference) {
+				return nil, fmt.Errorf("cleanup tree %s is the reference tree; use --exclude-reference-self to deduplicate it in place", c)
+			}
+		}
+	}
+
+	for _, p := range preferStrs {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --prefer pattern %q: %w", p, err)
+		}
+		cfg.prefer = append(cfg.prefer, re)
+	}
+
+	rules, err := parseMasterRules(masterRules)
+	if err != nil {
+		return nil, err
+	}
+	cfg.masterRules = rules
+	if len(cfg.masterRules) > 0 && !cfg.self {
+		return nil, fmt.Errorf("--master-rule requires --exclude-reference-self")
+	}
+
+	exts, err := parseExts(neverDeleteExts)
+	if err != nil {
+		return nil, err
+	}
+	cfg.neverDeleteExts = exts
+	return cfg, nil
+}
+
+// analyze scans all trees and returns the duplicate groups with survivors
+// selected. Nothing on disk is modified.
+func analyze(cfg *config, outFile *os.File) ([]duplicate, error) {
+	duplicates, err := findGroups(cfg, outFile)
+	if err != nil {
+		return nil, err
+	}
+	duplicates, linked := checkLinked(duplicates, outFile)
+	reportLinked(linked, outFile)
+	duplicates, withheld := protectExts(duplicates, cfg.neverDeleteExts, outFile)
+	reportWithheld(withheld, outFile)
+	duplicates, newer := checkNewer(duplicates, cfg.skipNewer, outFile)
+	reportNewer(newer, cfg.skipNewer, outFile)
+	return duplicates, nil
+}
+
+// findGroups is analyze before already-linked files are set aside and
+// --never-delete-ext and --skip-newer-than-reference are applied.
+func findGroups(cfg *config, outFile *os.File) ([]duplicate, error) {
+	if cfg.self {
+		return analyzeSelf(cfg, outFile)
+	}
+	referenceFiles, cleanupFiles, err := scanAll(cfg, outFile)
+	if err != nil {
+		return nil, err
 	}
+	cfg.referenceFiles = referenceFiles
 
-	if mode == ModePathOnly && outFile != nil {
+	duplicates := findDuplicates(referenceFiles, cleanupFiles, cfg.mode, cfg.window, cfg.hashWorkers, !cfg.noProgressive, outFile)
+	if cfg.manifestSample > 0 {
+		duplicates, cfg.manifestStale = verifyManifestSample(duplicates, cfg.reference, cfg.manifestSample, cfg.hashWorkers, outFile)
+	}
+	selectSurvivors(duplicates, cfg.prefer)
+	if cfg.keepOnePerTree {
+		duplicates = keepOnePerTree(duplicates)
+		output(outFile, fmt.Sprintf("Keeping one copy per cleanup tree; %d groups have extra copies", len(duplicates)))
+	}
+	return duplicates, nil
+}
+
+// analyzeStream is analyze for --stream: fn receives each group, survivors
+// already selected, as soon as it is found. Groups are not retained.
+func analyzeStream(cfg *config, outFile *os.File, fn func(duplicate)) error {
+	referenceFiles, cleanupFiles, err := scanAll(cfg, outFile)
+	if err != nil {
+		return err
+	}
+
+	linked, withheld, newer := 0, 0, 0
+	forEachDuplicate(referenceFiles, cleanupFiles, cfg.mode, cfg.window, cfg.hashWorkers, !cfg.noProgressive, outFile, func(dup duplicate) {
+		group := []duplicate{dup}
+		selectSurvivors(group, cfg.prefer)
+		if cfg.keepOnePerTree {
+			group = keepOnePerTree(group)
+		}
+		group, n := checkLinked(group, outFile)
+		linked += n
+		group, n = protectExts(group, cfg.neverDeleteExts, outFile)
+		withheld += n
+		group, n = checkNewer(group, cfg.skipNewer, outFile)
+		newer += n
+		for _, d := range group {
+			fn(d)
+		}
+	})
+	reportLinked(linked, outFile)
+	reportWithheld(withheld, outFile)
+	reportNewer(newer, cfg.skipNewer, outFile)
+	return nil
+}
+
+// scanAll prints the analysis warnings and scans the reference and cleanup
+// trees, returning the reference files and all cleanup files.
+func scanAll(cfg *config, outFile *os.File) ([]*file, []*file, error) {
+	if cfg.mode == ModePathOnly && outFile != nil {
 		fmt.Fprintln(outFile, "\n⚠️  WARNING: Using 'path' mode - files matched by path ONLY!")
 		fmt.Fprintln(outFile, "   Files with different content but same path will be considered duplicates.")
 		fmt.Fprintln(outFile, "   This is UNSAFE unless you have identical directory structures.")
 	}
 
-	start := time.Now()
+	if cfg.window.active() {
+		output(outFile, "\n⚠️  WARNING: Partial-content hashing enabled!")
+		output(outFile, fmt.Sprintf("   The first %d and last %d bytes of every file are ignored.", cfg.window.head, cfg.window.tail))
+		output(outFile, "   Files that differ in their header/footer will be considered duplicates.")
+		output(outFile, "   Deletion requires --force-unverified.")
+	}
 
-	// Scan reference tree
-	output(outFile, fmt.Sprintf("Scanning reference tree: %s", reference))
-	referenceFiles, err := scanTree(reference)
+	// Scan reference and cleanup trees concurrently
+	roots := append([]string{cfg.reference}, cfg.cleanup...)
+	if cfg.referenceManifest != "" {
+		output(outFile, fmt.Sprintf("Reference manifest: %s (archive %s)", cfg.referenceManifest, cfg.reference))
+		roots = cfg.cleanup
+	} else {
+		output(outFile, fmt.Sprintf("Scanning reference tree: %s", cfg.reference))
+	}
+	for _, cleanupTree := range cfg.cleanup {
+		output(outFile, fmt.Sprintf("Scanning cleanup tree: %s", cleanupTree))
+	}
+	scanStart := time.Now()
+	scanned, stats, err := scanTrees(roots, cfg.includeHidden)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	output(outFile, fmt.Sprintf("Found %d files in reference tree", len(referenceFiles)))
+	stats.report(outFile)
 
-	// Scan cleanup trees
+	referenceFiles, cleanupScans := cfg.manifestFiles, scanned
+	if cfg.referenceManifest == "" {
+		referenceFiles, cleanupScans = scanned[0], scanned[1:]
+	}
+	output(outFile, fmt.Sprintf("Found %d files in reference tree", len(referenceFiles)))
 	var allCleanupFiles []*file
-	for _, cleanupTree := range cleanup {
-		output(outFile, fmt.Sprintf("Scanning cleanup tree: %s", cleanupTree))
-		cleanupFiles, err := scanTree(cleanupTree)
+	cfg.treeFiles = make(map[string]int, len(cfg.cleanup))
+	for i, cleanupFiles := range cleanupScans {
+		cfg.treeFiles[treeRoot(cfg.cleanup[i])] = len(cleanupFiles)
+		output(outFile, fmt.Sprintf("Found %d files in cleanup tree %s", len(cleanupFiles), cfg.cleanup[i]))
+		allCleanupFiles = append(allCleanupFiles, cleanupFiles...)
+	}
+	output(outFile, fmt.Sprintf("Scanned %d trees in %v", len(scanned), time.Since(scanStart)))
+
+	if !cfg.followSymlinks {
+		var skippedRef, skippedCleanup int
+		referenceFiles, skippedRef = dropSymlinks(referenceFiles)
+		allCleanupFiles, skippedCleanup = dropSymlinks(allCleanupFiles)
+		if skippedRef+skippedCleanup > 0 {
+			output(outFile, fmt.Sprintf("Skipped %d symlinks (%d in reference, %d in cleanup); use --follow-symlinks to include them",
+				skippedRef+skippedCleanup, skippedRef, skippedCleanup))
+		}
+	}
+
+	// With nothing to compare against, "no duplicates" would be a false all-clear
+	if len(referenceFiles) == 0 && !cfg.allowEmptyReference {
+		return nil, nil, fmt.Errorf("reference tree %s has no files to compare against; check the path, or pass --allow-empty-reference", cfg.reference)
+	}
+
+	return referenceFiles, allCleanupFiles, nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	cfg, err := parseConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cfg.confirm, err = parseConfirmPolicy(cmd); err != nil {
+		return err
+	}
+	summaryPath, _ := cmd.Flags().GetString("summary-json")
+	dirLevel, _ := cmd.Flags().GetBool("dir-level")
+	if dirLevel && cfg.window.preview > 0 {
+		return fmt.Errorf("--dir-level cannot be combined with --preview")
+	}
+	if dirLevel && (cfg.stream || cfg.self || !cfg.mode.hashed()) {
+		return fmt.Errorf("--dir-level requires a hash mode (path+hash or hash) and cannot be combined with --stream or --exclude-reference-self")
+	}
+	relinkDupes, _ := cmd.Flags().GetBool("relink")
+	if relinkDupes {
+		if !hardlinksSupported {
+			return fmt.Errorf("--relink is not supported on this platform")
+		}
+		if !cfg.mode.hashed() || cfg.window.active() || cfg.window.preview > 0 {
+			return fmt.Errorf("--relink replaces copies with the reference's content and requires a full-hash mode (path+hash or hash)")
+		}
+		if cfg.moveTo != "" || cfg.trash || cfg.stream || dirLevel {
+			return fmt.Errorf("--relink cannot be combined with --move-to, --trash, --stream or --dir-level")
+		}
+	}
+	if cfg.referenceManifest != "" && (dirLevel || relinkDupes) {
+		return fmt.Errorf("--dir-level and --relink read the reference tree and cannot be combined with --reference-manifest")
+	}
+	if cfg.manifestSample > 0 && cfg.stream {
+		return fmt.Errorf("--verify-manifest-sample cannot be combined with --stream")
+	}
+	var limit runLimit
+	limit.files, _ = cmd.Flags().GetInt("limit-files")
+	limit.bytes, _ = cmd.Flags().GetInt64("limit-bytes")
+	if limit.files < 0 || limit.bytes < 0 {
+		return fmt.Errorf("--limit-files and --limit-bytes must not be negative")
+	}
+	if limit.active() && (cfg.stream || dirLevel || cfg.window.preview > 0) {
+		return fmt.Errorf("--limit-files and --limit-bytes cannot be combined with --stream, --dir-level or --preview")
+	}
+	exportPath, _ := cmd.Flags().GetString("export-reference-manifest")
+	if exportPath != "" {
+		if cfg.window.active() || cfg.window.preview > 0 {
+			return fmt.Errorf("--export-reference-manifest stores full-file hashes and cannot be combined with --preview or --skip-head-bytes/--skip-tail-bytes")
+		}
+		if cfg.self || cfg.stream || cfg.referenceManifest != "" {
+			return fmt.Errorf("--export-reference-manifest cannot be combined with --exclude-reference-self, --stream or --reference-manifest")
+		}
+	}
+	switch groupBy, _ := cmd.Flags().GetString("group-by"); groupBy {
+	case groupByKey:
+	case groupByReference:
+		if cfg.stream || relinkDupes {
+			return fmt.Errorf("--group-by reference needs every group at once and cannot be combined with --stream or --relink")
+		}
+		cfg.byReference = true
+	default:
+		return fmt.Errorf("invalid --group-by %q: use key or reference", groupBy)
+	}
+	// Flags are valid; errors from here on are about the trees themselves
+	cmd.SilenceUsage = true
+
+	var outFile *os.File
+	if cfg.outPath != "" {
+		outFile, err = os.Create(cfg.outPath)
 		if err != nil {
 			return err
 		}
-		output(outFile, fmt.Sprintf("Found %d files in cleanup tree", len(cleanupFiles)))
-		allCleanupFiles = append(allCleanupFiles, cleanupFiles...)
+		defer outFile.Close()
+	}
+
+	start := time.Now()
+
+	if cfg.stream {
+		return runStream(cfg, outFile, start)
 	}
 
-	duplicates := findDuplicates(referenceFiles, allCleanupFiles, mode, outFile)
+	duplicates, err := analyze(cfg, outFile)
+	if err != nil {
+		return err
+	}
+	// The reference tree is never modified, so the export holds either way
+	if exportPath != "" {
+		if err := exportReferenceManifest(exportPath, cfg.reference, cfg.referenceFiles, cfg.hashWorkers, outFile); err != nil {
+			return err
+		}
+	}
 	if len(duplicates) == 0 {
 		output(outFile, "No duplicates found.")
 		return nil
 	}
+	if cfg.window.preview > 0 {
+		output(outFile, "\n=== PREVIEW GROUPS (UNVERIFIED) ===")
+		reportGroups(duplicates, cfg, outFile)
+		output(outFile, previewNote)
+		output(outFile, fmt.Sprintf("\nDone in %v.", time.Since(start)))
+		return nil
+	}
+	if limit.active() {
+		if duplicates, err = applyLimit(duplicates, limit, outFile); err != nil {
+			return err
+		}
+	}
+	// Guards and previews count every file, whether removed alone or
+	// with its directory
+	all := duplicates
+	var dirs []dirDuplicate
+	if dirLevel {
+		dirs, duplicates = findDirDuplicates(duplicates, cfg.window)
+		output(outFile, fmt.Sprintf("Found %d cleanup directories that fully duplicate a reference directory", len(dirs)))
+	}
+
+	if reportOnly, _ := cmd.Flags().GetBool("report-only"); reportOnly {
+		if len(dirs) > 0 {
+			printDirDuplicates(dirs, cfg.moveTo, cfg.trash, outFile)
+		}
+		reportGroups(duplicates, cfg, outFile)
+		return nil
+	}
 
 	// Always show dry-run first
 	output(outFile, "\n=== DRY RUN RESULTS ===")
-	if err := processDuplicates(duplicates, true, false, moveTo, outFile); err != nil {
+	if len(dirs) > 0 {
+		printDirDuplicates(dirs, cfg.moveTo, cfg.trash, outFile)
+	}
+	if relinkDupes {
+		printRelinks(duplicates, outFile)
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			output(outFile, "\nDry-run enabled. No files affected.")
+			return nil
+		}
+		// Replacing files en masse is as telling of a wrong --reference as removing them
+		if err := checkBulk(cmd, cfg, all, outFile); err != nil {
+			return err
+		}
+		output(outFile, "\n=== RELINK OPERATIONS ===")
+		summary, err := relinkDuplicates(duplicates, cfg.confirm, outFile)
+		if serr := saveSummary(summaryPath, summary, outFile); serr != nil {
+			return errors.Join(err, serr)
+		}
+		if err != nil {
+			return err
+		}
+		output(outFile, fmt.Sprintf("\nDone in %v.", time.Since(start)))
+		return nil
+	}
+	if cfg.byReference {
+		reportGroups(duplicates, cfg, outFile)
+		output(outFile, "\nDry-run enabled. No files affected.")
+	} else if _, err := processDuplicates(duplicates, true, false, confirmPolicy{}, cfg.moveTo, cfg.trash, false, outFile); err != nil {
 		return err
 	}
+	if !cfg.keepEmptyDirs {
+		output(outFile, "\n=== Empty Directory Preview ===")
+		removeEmptyDirs(cfg.cleanup, plannedRemovals(all), true, outFile)
+	}
 
-	// Ask for confirmation
-	fmt.Println("\nProceed with operations? (y/N): ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() {
-		fmt.Println("Aborted.")
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
 		return nil
 	}
 
-	response := strings.ToLower(strings.TrimSpace(scanner.Text()))
-	if response != "y" && response != "yes" {
-		fmt.Println("Aborted.")
+	if err := checkBulk(cmd, cfg, all, outFile); err != nil {
+		return err
+	}
+
+	if cfg.manifestStale && !cfg.forceUnverified {
+		output(outFile, "\nThe reference manifest is stale. Rebuild it, or re-run with --force-unverified to act on the remaining matches.")
+		return nil
+	}
+	if cfg.window.active() && !cfg.forceUnverified {
+		output(outFile, "\nMatches are unverified (head/tail bytes skipped). Re-run with --force-unverified to act on them.")
 		return nil
 	}
 
-	// Perform actual operations
+	// Perform actual operations; processDuplicates asks for confirmation
 	output(outFile, "\n=== DELETION OPERATIONS ===")
-	if err := processDuplicates(duplicates, false, true, moveTo, outFile); err != nil {
+	policy := cfg.confirm
+	var dirOutcomes []dirOutcome
+	if len(dirs) > 0 {
+		if !confirmDirLevel(dirs, duplicates, policy, cfg.moveTo, cfg.trash, outFile) {
+			output(outFile, "Aborted.")
+			aborted := &runSummary{Finished: time.Now(), Action: actionVerb(cfg.moveTo, cfg.trash), MoveTo: cfg.moveTo, Aborted: true}
+			return saveSummary(summaryPath, aborted, outFile)
+		}
+		// One answer covers the directories and the single files
+		policy.assumeYes = true
+		var fallback map[*file]bool
+		dirOutcomes, fallback = processDirDuplicates(dirs, cfg.moveTo, cfg.trash, cfg.window, outFile)
+		duplicates = append(duplicates, regroup(all, fallback)...)
+	}
+	summary, err := processDuplicates(duplicates, false, true, policy, cfg.moveTo, cfg.trash, cfg.verifyMoveHash, outFile)
+	if summary != nil {
+		summary.Directories = dirOutcomes
+	}
+	// A failed run still writes its summary; losing either error would hide
+	// what went wrong
+	if serr := saveSummary(summaryPath, summary, outFile); serr != nil {
+		return errors.Join(err, serr)
+	}
+	if err != nil {
 		return err
 	}
+	if n := dirFailures(dirOutcomes); n > 0 {
+		return fmt.Errorf("%d directory operations failed", n)
+	}
 
 	// Empty directory cleanup (if not disabled)
-	if !keepEmptyDirs {
+	if !cfg.keepEmptyDirs {
 		output(outFile, "\n=== Empty Directory Cleanup ===")
-		removeEmptyDirs(cleanup, false, outFile)
+		removeEmptyDirs(cfg.cleanup, nil, false, outFile)
 	}
 
 	elapsed := time.Since(start)
@@ -454,19 +1345,102 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// reportGroups prints the duplicate groups and their totals for
+// --report-only: no previews, guards or prompts, and stdin is never read.
+func reportGroups(duplicates []duplicate, cfg *config, outFile *os.File) {
+	files := 0
+	for i, dup := range duplicates {
+		files += len(dup.cleanup)
+		if !cfg.byReference {
+			printGroup(i+1, dup, cfg.moveTo, cfg.trash, outFile)
+		}
+	}
+	if cfg.byReference {
+		printByReference(duplicates, cfg.moveTo, cfg.trash, outFile)
+	}
+	output(outFile, fmt.Sprintf("\nWould remove %d duplicate files across %d groups", files, len(duplicates)))
+}
+
+// runStream reports duplicate groups as they are found. It never modifies
+// files; acting on a streamed analysis goes through plan and apply.
+func runStream(cfg *config, outFile *os.File, start time.Time) error {
+	output(outFile, "\n=== DUPLICATE GROUPS (streaming) ===")
+	groups, files := 0, 0
+	err := analyzeStream(cfg, outFile, func(dup duplicate) {
+		groups++
+		files += len(dup.cleanup)
+		printGroup(groups, dup, cfg.moveTo, cfg.trash, outFile)
+	})
+	if err != nil {
+		return err
+	}
+
+	output(outFile, fmt.Sprintf("\nWould remove %d duplicate files across %d groups", files, groups))
+	if cfg.window.preview > 0 {
+		output(outFile, previewNote)
+	} else {
+		output(outFile, "Streaming is report-only. Use 'dupekill plan --stream' and 'dupekill apply' to act on it.")
+	}
+	output(outFile, fmt.Sprintf("\nDone in %v.", time.Since(start)))
+	return nil
+}
+
+// previewNote closes every --preview report.
+const previewNote = "Preview groups match on size and the first and last 64 KB only; they are likely, not verified, duplicates.\n" +
+	"Nothing is removed in preview mode. Run without --preview to hash fully and act."
+
 var Cmd = &cobra.Command{
 	Use:   "dupekill",
 	Short: "Remove duplicate files from cleanup trees that exist in reference tree",
 	RunE:  run,
 }
 
+// addScanFlags registers the scan/match flags shared by dupekill and plan.
+func addScanFlags(c *cobra.Command) {
+	c.Flags().String("reference", "", "reference tree (files to keep, never modified)")
+	c.Flags().StringSlice("cleanup", nil, "trees to clean up (remove duplicates from)")
+	c.Flags().String("mode", "hash", "dedup mode: path | path+name | path+hash | hash")
+	c.Flags().Bool("require-name-match", false, "with --mode hash, also require the same base name as the reference, so renamed files with matching bytes are kept")
+	c.Flags().String("move-to", "", "move duplicates to directory")
+	c.Flags().Bool("trash", false, "send duplicates to the OS recycle bin/trash instead of deleting them permanently")
+	c.Flags().Bool("verify-move-hash", false, "when a move falls back to copy, also compare hashes before removing the source")
+	c.Flags().String("out", "", "output report file")
+	c.Flags().Bool("keep-empty-dirs", false, "keep empty directories (default: remove them after deduplication)")
+	c.Flags().Bool("allow-empty-reference", false, "proceed even if the reference tree has no files (otherwise refused as a likely wrong path)")
+	c.Flags().Bool("include-hidden", false, "include dotfiles, dot-directories (e.g. .git) and files with the Windows hidden attribute")
+	c.Flags().Bool("follow-symlinks", false, "treat symlinks to files as candidates (only the link is removed)")
+	c.Flags().Bool("stream", false, "report each duplicate group as soon as it is found instead of collecting them all (report-only; plan writes groups incrementally)")
+	c.Flags().Bool("exclude-reference-self", false, "deduplicate one tree in place: pass the same directory as --reference and --cleanup (hash mode)")
+	c.Flags().Bool("keep-one-per-tree", false, "keep one copy in each cleanup tree (smallest path, or the --prefer survivor) and remove only the extras")
+	c.Flags().Bool("skip-newer-than-reference", false, "keep cleanup files modified after their reference copy instead of only warning about them")
+	c.Flags().StringArray("never-delete-ext", nil, "never remove cleanup files with this extension, e.g. .raw (repeatable, case-insensitive)")
+	c.Flags().Bool("no-progressive", false, "hash modes: hash every file in full instead of first pruning by size and a partial hash")
+	c.Flags().StringSlice("master-rule", nil, "with --exclude-reference-self, pick the survivor by rule when no --prefer pattern matches: shallowest, tidy (comma-separated, first rule wins)")
+	c.Flags().StringArray("prefer", nil, "regex on file name; among duplicates keep the first match (repeatable, highest priority first)")
+	c.Flags().Int("hash-workers", defaultHashWorkers, "number of files hashed in parallel")
+	c.Flags().Int64("skip-head-bytes", 0, "UNVERIFIED: ignore this many leading bytes when hashing")
+	c.Flags().Int64("skip-tail-bytes", 0, "UNVERIFIED: ignore this many trailing bytes when hashing")
+	c.Flags().Bool("force-unverified", false, "allow deleting matches found with --skip-head-bytes/--skip-tail-bytes, or against a reference manifest that failed --verify-manifest-sample")
+	c.MarkFlagRequired("cleanup")
+}
+
 func init() {
-	Cmd.Flags().String("reference", "", "reference tree (files to keep, never modified)")
-	Cmd.Flags().StringSlice("cleanup", nil, "trees to clean up (remove duplicates from)")
-	Cmd.Flags().String("mode", "hash", "dedup mode: path | path+name | path+hash | hash")
-	Cmd.Flags().String("move-to", "", "move duplicates to directory")
-	Cmd.Flags().String("out", "", "output report file")
-	Cmd.Flags().Bool("keep-empty-dirs", false, "keep empty directories (default: remove them after deduplication)")
-	Cmd.MarkFlagRequired("reference")
-	Cmd.MarkFlagRequired("cleanup")
+	addScanFlags(Cmd)
+	addConfirmFlags(Cmd)
+	Cmd.Flags().String("reference-manifest", "", "use a manifest saved by 'ds scan --hash' (sha256) as the reference tree instead of --reference, e.g. for an offline archive")
+	Cmd.Flags().String("export-reference-manifest", "", "save the reference tree's sha256 hashes to this manifest, reusing those the run computed, for a later --reference-manifest or 'twincheck --self-check'")
+	Cmd.Flags().Float64("verify-manifest-sample", 0, "with --reference-manifest, when the archive is reachable, re-hash this percent of the reference files the matches rely on and drop groups whose entry is stale")
+	Cmd.MarkFlagsOneRequired("reference", "reference-manifest")
+	Cmd.MarkFlagsMutuallyExclusive("reference", "reference-manifest")
+	Cmd.Flags().String("summary-json", "", "after a real run, write what happened (counts, bytes, per-file outcomes and failures) as JSON to this file")
+	Cmd.Flags().Bool("preview", false, "fast triage: group by size plus the first and last 64 KB instead of full hashes and report the likely duplicates; never removes anything")
+	Cmd.Flags().Bool("dir-level", false, "remove cleanup directories whose every file duplicates a reference directory at the same relative paths as a whole, reported apart from single files (hash modes)")
+	Cmd.Flags().Bool("relink", false, "replace each duplicate with a hardlink to its reference instead of removing it, restoring links that were broken (full-hash modes, same filesystem)")
+	Cmd.Flags().String("group-by", groupByKey, "how to list duplicates: key (one group per match) or reference (per reference file, with its location and size, then its cleanup copies)")
+	Cmd.Flags().Bool("report-only", false, "print the duplicate groups and exit without previews, prompts or changes (safe to run headless)")
+	Cmd.Flags().Int("limit-files", 0, "act on at most this many duplicates per run, largest first; later runs pick up the rest (0 = no limit)")
+	Cmd.Flags().Int64("limit-bytes", 0, "act on at most this many bytes of duplicates per run, largest first; later runs pick up the rest (0 = no limit)")
+	addBulkFlags(Cmd)
+	Cmd.AddCommand(planCmd)
+	Cmd.AddCommand(applyCmd)
 }
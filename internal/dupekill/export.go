@@ -0,0 +1,50 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/manifest"
+)
+
+// exportReferenceManifest writes the reference files to path in the shared
+// manifest format, for --reference-manifest or 'twincheck --self-check'.
+// Hashes the analysis already computed are reused; progressive hashing
+// leaves most reference files unhashed, and those are hashed now so every
+// readable file has an entry a later run can match. Symlinks are left
+// out, and root is recorded absolute, as 'ds scan' does.
+func exportReferenceManifest(path, root string, files []*file, workers int, out *os.File) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	var kept, missing []*file
+	for _, f := range files {
+		if f.symlink {
+			continue
+		}
+		kept = append(kept, f)
+		if f.hash == "" {
+			missing = append(missing, f)
+		}
+	}
+	reused := len(kept) - len(missing)
+	if len(missing) > 0 {
+		output(out, fmt.Sprintf("Hashing %d reference files not hashed by the analysis for the manifest", len(missing)))
+	}
+	failures := hashFiles(missing, hashWindow{}, workers)
+
+	m := manifest.Manifest{Version: manifest.Version, Root: root, Created: time.Now().UTC(), Algorithm: "sha256"}
+	for _, f := range kept {
+		m.Files = append(m.Files, manifest.Entry{Path: filepath.ToSlash(f.rel), Size: f.size, ModTime: f.modTime.UTC(), Hash: f.hash})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	if err := m.Save(path); err != nil {
+		return fmt.Errorf("writing reference manifest %s: %w", path, err)
+	}
+	output(out, fmt.Sprintf("Exported %d reference files to %s (%d hashes reused, %d unreadable)", len(kept), path, reused, len(failures)))
+	return nil
+}
@@ -0,0 +1,45 @@
+package dupekill
+
+type linkID struct {
+	dev uint64
+	ino uint64
+}
+
+type linkState struct {
+	id    linkID
+	nlink uint64
+	ok    bool
+}
+
+// statLinks records link identity for files before they are removed.
+func statLinks(files []*file) map[*file]linkState {
+	states := make(map[*file]linkState, len(files))
+	for _, f := range files {
+		id, nlink, ok := linkInfo(f.abs)
+		states[f] = linkState{id: id, nlink: nlink, ok: ok}
+	}
+	return states
+}
+
+// spaceFreed returns the nominal bytes removed and the bytes actually
+// released. An inode's bytes only count once all of its links were
+// removed; symlinks release no data. Files whose identity is unknown count
+// at their nominal size.
+func spaceFreed(removed []*file, states map[*file]linkState) (nominal, actual int64) {
+	removedLinks := make(map[linkID]uint64)
+	for _, f := range removed {
+		nominal += f.size
+		st := states[f]
+		switch {
+		case f.symlink:
+		case !st.ok:
+			actual += f.size
+		default:
+			removedLinks[st.id]++
+			if removedLinks[st.id] == st.nlink {
+				actual += f.size
+			}
+		}
+	}
+	return nominal, actual
+}
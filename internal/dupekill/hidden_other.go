@@ -0,0 +1,14 @@
+//go:build !windows
+
+package dupekill
+
+import (
+	"os"
+	"strings"
+)
+
+// isHidden reports whether a directory entry is hidden: a dotfile or
+// dot-directory.
+func isHidden(entry os.DirEntry) bool {
+	return strings.HasPrefix(entry.Name(), ".")
+}
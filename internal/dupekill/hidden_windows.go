@@ -0,0 +1,23 @@
+//go:build windows
+
+package dupekill
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isHidden reports whether a directory entry is hidden: a dotfile or
+// dot-directory, or anything carrying the Windows hidden attribute.
+func isHidden(entry os.DirEntry) bool {
+	if strings.HasPrefix(entry.Name(), ".") {
+		return true
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package dupekill
+
+import "os"
+
+// hardlinksSupported reports whether linkInfo can identify hardlinks.
+const hardlinksSupported = false
+
+// linkInfo is not supported here; callers fall back to nominal sizes.
+func linkInfo(path string) (linkID, uint64, bool) {
+	return linkID{}, 0, false
+}
+
+// fileID is not supported here; every file has the zero identity.
+func fileID(info os.FileInfo) linkID {
+	return linkID{}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package dupekill
+
+import (
+	"os"
+	"syscall"
+)
+
+// hardlinksSupported reports whether linkInfo can identify hardlinks.
+const hardlinksSupported = true
+
+// linkInfo returns the device+inode identity and link count of path
+// without following symlinks.
+func linkInfo(path string) (linkID, uint64, bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return linkID{}, 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return linkID{}, 0, false
+	}
+	return linkID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// fileID returns the device+inode identity recorded in info.
+func fileID(info os.FileInfo) linkID {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return linkID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+	}
+	return linkID{}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package dupekill
+
+import "errors"
+
+// inUse reports whether another process holds path open in a way that
+// blocks removing it. Unix lets open files be unlinked and renamed, so
+// nothing is ever in the way.
+func inUse(path string) bool {
+	return false
+}
+
+// isInUseErr reports whether err means the file is open in another process.
+func isInUseErr(err error) bool {
+	return errors.Is(err, errFileInUse)
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package dupekill
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+	accessDelete                        = 0x00010000
+)
+
+// inUse reports whether another process holds path open without sharing
+// delete access, so removing or moving it would fail. It opens the file
+// for delete the way a removal does and looks for a sharing violation.
+func inUse(path string) bool {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	h, err := syscall.CreateFile(p, accessDelete,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return isInUseErr(err)
+	}
+	syscall.CloseHandle(h)
+	return false
+}
+
+// isInUseErr reports whether err means the file is open in another process.
+func isInUseErr(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) || errors.Is(err, errFileInUse)
+}
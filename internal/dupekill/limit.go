@@ -0,0 +1,77 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// runLimit caps how much one run removes, for cleaning a huge duplicate set
+// in bounded chunks. Zero fields are unlimited.
+type runLimit struct {
+	files int
+	bytes int64
+}
+
+func (l runLimit) active() bool {
+	return l.files > 0 || l.bytes > 0
+}
+
+// applyLimit keeps the largest cleanup files that fit the limit and drops
+// the rest from the groups, along with groups left with nothing to remove.
+// Files are taken largest first to reclaim the most space per run; one
+// that would overrun --limit-bytes is skipped and smaller ones keep filling
+// the budget. It fails only when no file fits at all. Files left out are
+// simply found again by the next run.
+func applyLimit(duplicates []duplicate, limit runLimit, outFile *os.File) ([]duplicate, error) {
+	var all []*file
+	var totalBytes int64
+	for _, dup := range duplicates {
+		all = append(all, dup.cleanup...)
+		for _, f := range dup.cleanup {
+			totalBytes += f.size
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].size != all[j].size {
+			return all[i].size > all[j].size
+		}
+		return all[i].abs < all[j].abs
+	})
+
+	chosen := make(map[*file]bool)
+	var chosenBytes int64
+	for _, f := range all {
+		if limit.files > 0 && len(chosen) >= limit.files {
+			break
+		}
+		if limit.bytes > 0 && chosenBytes+f.size > limit.bytes {
+			continue
+		}
+		chosen[f] = true
+		chosenBytes += f.size
+	}
+	if len(chosen) == len(all) {
+		return duplicates, nil
+	}
+	if len(chosen) == 0 {
+		return nil, fmt.Errorf("no duplicate fits --limit-bytes %d; the smallest is %d bytes", limit.bytes, all[len(all)-1].size)
+	}
+
+	var result []duplicate
+	for _, dup := range duplicates {
+		var remaining []*file
+		for _, f := range dup.cleanup {
+			if chosen[f] {
+				remaining = append(remaining, f)
+			}
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			result = append(result, dup)
+		}
+	}
+	output(outFile, fmt.Sprintf("Limited this run to %d of %d duplicate files (%d of %d bytes, largest first)", len(chosen), len(all), chosenBytes, totalBytes))
+	output(outFile, fmt.Sprintf("%d files (%d bytes) remain for later runs", len(all)-len(chosen), totalBytes-chosenBytes))
+	return result, nil
+}
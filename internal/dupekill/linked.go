@@ -0,0 +1,129 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// alreadyLinked reports whether f is a hardlink of its reference: the same
+// device and inode. Removing it would free nothing and break a deliberate
+// link. Symlinks are not hardlinks, even though their identity is that of
+// the target.
+func alreadyLinked(f *file, dup duplicate) bool {
+	return f.id != (linkID{}) && f.id == dup.reference.id && !f.symlink && !dup.reference.symlink
+}
+
+// checkLinked takes cleanup files that are hardlinks of their reference off
+// the delete list, listing each, and drops groups left with nothing to
+// remove. It returns how many were skipped.
+func checkLinked(duplicates []duplicate, outFile *os.File) ([]duplicate, int) {
+	var result []duplicate
+	linked := 0
+	for _, dup := range duplicates {
+		var remaining []*file
+		for _, f := range dup.cleanup {
+			if alreadyLinked(f, dup) {
+				linked++
+				output(outFile, fmt.Sprintf("Already linked to reference, skipped: %s", f.abs))
+				continue
+			}
+			remaining = append(remaining, f)
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			result = append(result, dup)
+		}
+	}
+	return result, linked
+}
+
+// reportLinked prints the count from checkLinked.
+func reportLinked(linked int, outFile *os.File) {
+	if linked > 0 {
+		output(outFile, fmt.Sprintf("Skipped %d cleanup files that are already hardlinks of their reference", linked))
+	}
+}
+
+// printRelinks lists what --relink would do to each group.
+func printRelinks(duplicates []duplicate, outFile *os.File) {
+	total := 0
+	for _, dup := range duplicates {
+		total += len(dup.cleanup)
+	}
+	output(outFile, fmt.Sprintf("\nWould relink %d duplicate files across %d groups", total, len(duplicates)))
+	for i, dup := range duplicates {
+		output(outFile, fmt.Sprintf("\nGroup %d:", i+1))
+		output(outFile, fmt.Sprintf("  Reference: %s", dup.reference.label()))
+		for _, f := range dup.cleanup {
+			output(outFile, fmt.Sprintf("  Relink: %s", f.label()))
+		}
+	}
+}
+
+// relinkDuplicates replaces each cleanup copy with a hardlink to its
+// reference, so both paths stay but the content is stored once. The link
+// is made under a temporary name and renamed over the copy, so a failure
+// (e.g. the trees are on different filesystems) leaves the copy as it was.
+func relinkDuplicates(duplicates []duplicate, policy confirmPolicy, outFile *os.File) (*runSummary, error) {
+	var files []*file
+	var bytes int64
+	for _, dup := range duplicates {
+		for _, f := range dup.cleanup {
+			files = append(files, f)
+			bytes += f.size
+		}
+	}
+	summary := &runSummary{Action: "relink", Groups: len(duplicates), FilesPlanned: len(files)}
+	if !policy.confirm("relink", len(files), bytes) {
+		output(outFile, "Aborted.")
+		summary.Aborted = true
+		summary.Finished = time.Now()
+		return summary, nil
+	}
+
+	states := statLinks(files)
+	var relinked []*file
+	failed := 0
+	for _, dup := range duplicates {
+		group := groupOutcome{Reference: dup.reference.abs}
+		for _, f := range dup.cleanup {
+			outcome := fileOutcome{Path: f.abs, Size: f.size, Status: "relinked"}
+			if err := relink(dup.reference.abs, f.abs); err != nil {
+				output(outFile, fmt.Sprintf("Failed to relink %s: %v", f.abs, err))
+				failed++
+				outcome.Status, outcome.Error = "failed", err.Error()
+			} else {
+				relinked = append(relinked, f)
+			}
+			group.Files = append(group.Files, outcome)
+		}
+		summary.GroupOutcomes = append(summary.GroupOutcomes, group)
+	}
+
+	// A relinked copy releases its data like a deleted one
+	nominal, actual := spaceFreed(relinked, states)
+	summary.FilesRemoved, summary.FilesFailed = len(relinked), failed
+	summary.BytesRemoved, summary.BytesFreed = nominal, &actual
+	summary.Finished = time.Now()
+	output(outFile, fmt.Sprintf("Actual space freed:   %d bytes", actual))
+	if failed > 0 {
+		return summary, fmt.Errorf("%d operations failed", failed)
+	}
+	output(outFile, fmt.Sprintf("Successfully relinked %d duplicate files", len(relinked)))
+	return summary, nil
+}
+
+// relink replaces path with a hardlink to target.
+func relink(target, path string) error {
+	tmp := filepath.Join(filepath.Dir(path), ".dupekill-relink-"+filepath.Base(path))
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
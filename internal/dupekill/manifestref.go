@@ -0,0 +1,128 @@
+package dupekill
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/manifest"
+)
+
+// loadReferenceManifest reads a manifest saved by 'ds scan' as the
+// reference tree, for an archive that is offline or slow to scan. Its
+// files arrive hashed, so only the cleanup trees are read. Entries
+// without a hash (unreadable when the manifest was made) match nothing.
+// A relative root is refused: it was relative to wherever the manifest
+// was made, so guessing would point the run at the wrong tree.
+func loadReferenceManifest(path string) (*manifest.Manifest, []*file, error) {
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !filepath.IsAbs(m.Root) {
+		return nil, nil, fmt.Errorf("reference manifest %s has root %q, not an absolute local path; rebuild it with 'ds scan'", path, m.Root)
+	}
+	if m.Algorithm != "sha256" {
+		if !m.HasHashes() {
+			return nil, nil, fmt.Errorf("reference manifest %s has no hashes; rebuild it with 'ds scan --hash'", path)
+		}
+		return nil, nil, fmt.Errorf("reference manifest %s uses %s hashes; dupekill compares sha256", path, m.Algorithm)
+	}
+	root := treeRoot(m.Root)
+	files := make([]*file, 0, len(m.Files))
+	for _, e := range m.Files {
+		if e.Hash == "" {
+			continue
+		}
+		rel := filepath.FromSlash(e.Path)
+		files = append(files, &file{root: root, rel: rel, abs: filepath.Join(m.Root, rel), size: e.Size, modTime: e.ModTime, hash: e.Hash})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].abs < files[j].abs })
+	return m, files, nil
+}
+
+// hashAgainstReference hashes the cleanup files that share a size (and,
+// in path+hash and hash+name modes, a path or name) with a reference file
+// whose hash is already known.
+func hashAgainstReference(referenceFiles, cleanupFiles []*file, mode Mode, workers int, out *os.File) []hashFailure {
+	key := func(f *file) string {
+		switch mode {
+		case ModePathHash:
+			return fmt.Sprintf("%s|%d", f.rel, f.size)
+		case ModeHashName:
+			return fmt.Sprintf("%s|%d", filepath.Base(f.rel), f.size)
+		}
+		return fmt.Sprint(f.size)
+	}
+	sizes := make(map[string]bool, len(referenceFiles))
+	for _, f := range referenceFiles {
+		sizes[key(f)] = true
+	}
+	var candidates []*file
+	for _, f := range cleanupFiles {
+		if sizes[key(f)] {
+			candidates = append(candidates, f)
+		}
+	}
+	output(out, fmt.Sprintf("Hashing %d of %d cleanup files that match a manifest entry by size", len(candidates), len(cleanupFiles)))
+	return hashFiles(candidates, hashWindow{}, workers)
+}
+
+// verifyManifestSample re-hashes a random pct percent of the reference
+// files the duplicate groups rely on, when the archive behind the
+// manifest is reachable, to catch a manifest gone stale. A reference that
+// is gone or whose content changed drops its group. It returns the
+// remaining groups and whether any sampled file disagreed. An unreachable
+// archive is trusted as is.
+func verifyManifestSample(duplicates []duplicate, root string, pct float64, workers int, out *os.File) ([]duplicate, bool) {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		output(out, fmt.Sprintf("Archive %s is not reachable; trusting the manifest without verification", root))
+		return duplicates, false
+	}
+	n := int(math.Ceil(float64(len(duplicates)) * pct / 100))
+	if n == 0 {
+		return duplicates, false
+	}
+	order := rand.Perm(len(duplicates))[:n]
+	sample := make([]*file, n)
+	recorded := make(map[*file]string, n)
+	for i, idx := range order {
+		ref := duplicates[idx].reference
+		// Hash a copy so a failure cannot clear the manifest's hash
+		c := *ref
+		c.hash = ""
+		sample[i] = &c
+		recorded[&c] = ref.hash
+	}
+	output(out, fmt.Sprintf("Verifying %d of %d reference files against the archive (--verify-manifest-sample %g)...", n, len(duplicates), pct))
+	failed := hashFiles(sample, hashWindow{}, workers)
+
+	stale := make(map[string]bool)
+	for _, f := range failed {
+		output(out, fmt.Sprintf("  Manifest disagrees: %s: %v", f.path, f.err))
+		stale[f.path] = true
+	}
+	for _, f := range sample {
+		if f.hash != "" && f.hash != recorded[f] {
+			output(out, fmt.Sprintf("  Manifest disagrees: %s: content changed since the manifest was made", f.abs))
+			stale[f.abs] = true
+		}
+	}
+	if len(stale) == 0 {
+		output(out, fmt.Sprintf("All %d sampled reference files match the manifest", n))
+		return duplicates, false
+	}
+
+	var kept []duplicate
+	for _, dup := range duplicates {
+		if !stale[dup.reference.abs] {
+			kept = append(kept, dup)
+		}
+	}
+	output(out, fmt.Sprintf("\n⚠️  WARNING: %d of %d sampled reference files no longer match the manifest; their groups were dropped.", len(stale), n))
+	output(out, "   The other matches may be stale too. Rebuild the manifest, or re-run with --force-unverified to act on them anyway.")
+	return kept, true
+}
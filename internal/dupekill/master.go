@@ -0,0 +1,70 @@
+package dupekill
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// masterScores score the copies of a group for --master-rule; the copy
+// with the lowest score survives. Rules are tie-breakers in the order
+// given.
+var masterScores = map[string]func(f *file) int{
+	// shallowest: fewest directories between the tree root and the file
+	"shallowest": func(f *file) int {
+		return strings.Count(f.rel, string(filepath.Separator))
+	},
+	// tidy: fewest directories named like a copy, backup or old version
+	"tidy": func(f *file) int {
+		n := 0
+		for _, dir := range strings.Split(filepath.Dir(f.rel), string(filepath.Separator)) {
+			if copyDirName.MatchString(dir) {
+				n++
+			}
+		}
+		return n
+	},
+}
+
+// copyDirName matches folder names that suggest a stray copy, such as
+// "copy", "Copy of x", "backup", "bak", "duplicates", "old" or "old2".
+var copyDirName = regexp.MustCompile(`(?i)(^|[^a-z])(cop(y|ies)|duplicates?|dupes?|backups?|bak|old)([^a-z]|$)`)
+
+// parseMasterRules validates --master-rule names.
+func parseMasterRules(names []string) ([]string, error) {
+	for _, n := range names {
+		if _, ok := masterScores[n]; !ok {
+			return nil, fmt.Errorf("invalid --master-rule %q (use: shallowest, tidy)", n)
+		}
+	}
+	return names, nil
+}
+
+// pickMaster chooses the survivor of group by rules, each narrowing the
+// copies left by the one before. Ties that remain go to the first copy by
+// path. It returns the survivor and the last rule that narrowed the
+// choice, or "" when no rule told the copies apart.
+func pickMaster(group []*file, rules []string) (*file, string) {
+	candidates, decided := group, ""
+	for _, name := range rules {
+		score := masterScores[name]
+		best := score(candidates[0])
+		kept := []*file{candidates[0]}
+		for _, f := range candidates[1:] {
+			switch s := score(f); {
+			case s < best:
+				best, kept = s, []*file{f}
+			case s == best:
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) < len(candidates) {
+			candidates, decided = kept, name
+		}
+		if len(candidates) == 1 {
+			break
+		}
+	}
+	return candidates[0], decided
+}
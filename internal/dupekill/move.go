@@ -0,0 +1,27 @@
+package dupekill
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/move"
+)
+
+// errFileInUse marks a source another process holds open, found before a
+// cross-device move copied it.
+var errFileInUse = errors.New("file is in use by another process")
+
+// moveFile moves src to dst without overwriting, verifying a cross-device
+// copy's size (and content if verifyHash is set) before the source is
+// removed. A source held open elsewhere is not copied at all.
+func moveFile(src, dst string, verifyHash bool) error {
+	return move.File(src, dst, move.Options{
+		VerifyHash: verifyHash,
+		BeforeCopy: func(src string) error {
+			if inUse(src) {
+				return fmt.Errorf("%s: %w", src, errFileInUse)
+			}
+			return nil
+		},
+	})
+}
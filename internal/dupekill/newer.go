@@ -0,0 +1,51 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+)
+
+// newerThanReference reports whether f was modified after the reference it
+// duplicates, in which case the cleanup copy may be the one last edited
+// and the reference the stale one.
+func newerThanReference(f *file, dup duplicate) bool {
+	return f.modTime.After(dup.reference.modTime)
+}
+
+// checkNewer counts cleanup files newer than their reference and warns
+// about them. With skip they are taken off the delete list instead, and
+// groups left with nothing to remove are dropped.
+func checkNewer(duplicates []duplicate, skip bool, outFile *os.File) ([]duplicate, int) {
+	var result []duplicate
+	newer := 0
+	for _, dup := range duplicates {
+		var remaining []*file
+		for _, f := range dup.cleanup {
+			if newerThanReference(f, dup) {
+				newer++
+				if skip {
+					output(outFile, fmt.Sprintf("Newer than reference, skipped: %s", f.abs))
+					continue
+				}
+			}
+			remaining = append(remaining, f)
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			result = append(result, dup)
+		}
+	}
+	return result, newer
+}
+
+// reportNewer prints the count from checkNewer.
+func reportNewer(newer int, skip bool, outFile *os.File) {
+	switch {
+	case newer == 0:
+	case skip:
+		output(outFile, fmt.Sprintf("Skipped %d cleanup files newer than their reference (--skip-newer-than-reference)", newer))
+	default:
+		output(outFile, fmt.Sprintf("\n⚠️  WARNING: %d cleanup files are newer than their reference copy and may be the ones last edited.", newer))
+		output(outFile, "   They are marked \"newer than reference\" in the groups; review them or pass --skip-newer-than-reference.")
+	}
+}
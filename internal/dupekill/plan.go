@@ -0,0 +1,501 @@
+package dupekill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// plan is the durable result of a dupekill analysis. It records everything
+// apply needs to act later, including hashes to re-verify files against.
+type plan struct {
+	Created       time.Time   `json:"created"`
+	Reference     string      `json:"reference"`
+	Cleanup       []string    `json:"cleanup"`
+	Mode          Mode        `json:"mode"`
+	MoveTo        string      `json:"move_to,omitempty"`
+	Trash         bool        `json:"trash,omitempty"`
+	KeepEmptyDirs bool        `json:"keep_empty_dirs"`
+	SkipHead      int64       `json:"skip_head_bytes,omitempty"`
+	SkipTail      int64       `json:"skip_tail_bytes,omitempty"`
+	NeverDelete   []string    `json:"never_delete_ext,omitempty"`
+	IncludeHidden bool        `json:"include_hidden,omitempty"`
+	Groups        []planGroup `json:"groups"`
+}
+
+type planGroup struct {
+	Reference planFile   `json:"reference"`
+	Keep      *planFile  `json:"keep,omitempty"`
+	Rule      string     `json:"rule,omitempty"`
+	Master    string     `json:"master_rule,omitempty"`
+	Retain    []planFile `json:"retain,omitempty"`
+	Remove    []planFile `json:"remove"`
+}
+
+type planFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash,omitempty"`
+	Symlink bool      `json:"symlink,omitempty"`
+}
+
+func toPlanFile(f *file) planFile {
+	return planFile{Path: f.abs, Size: f.size, ModTime: f.modTime.UTC(), Hash: f.hash, Symlink: f.symlink}
+}
+
+func (pf planFile) toFile() *file {
+	return &file{abs: pf.Path, size: pf.Size, modTime: pf.ModTime, hash: pf.Hash, symlink: pf.Symlink}
+}
+
+func (p *plan) window() hashWindow {
+	return hashWindow{head: p.SkipHead, tail: p.SkipTail}
+}
+
+func newPlan(cfg *config, duplicates []duplicate) *plan {
+	p := planHeader(cfg)
+	for _, dup := range duplicates {
+		p.Groups = append(p.Groups, toPlanGroup(dup))
+	}
+	return p
+}
+
+// planHeader is a plan for cfg with no groups yet.
+func planHeader(cfg *config) *plan {
+	return &plan{
+		Created:       time.Now().UTC(),
+		Reference:     cfg.reference,
+		Cleanup:       cfg.cleanup,
+		Mode:          cfg.mode,
+		MoveTo:        cfg.moveTo,
+		Trash:         cfg.trash,
+		KeepEmptyDirs: cfg.keepEmptyDirs,
+		SkipHead:      cfg.window.head,
+		SkipTail:      cfg.window.tail,
+		NeverDelete:   cfg.neverDeleteExts,
+		IncludeHidden: cfg.includeHidden,
+		Groups:        []planGroup{},
+	}
+}
+
+func toPlanGroup(dup duplicate) planGroup {
+	g := planGroup{Reference: toPlanFile(dup.reference), Rule: dup.rule, Master: dup.master}
+	if dup.kept != nil {
+		kept := toPlanFile(dup.kept)
+		g.Keep = &kept
+	}
+	for _, f := range dup.retained {
+		g.Retain = append(g.Retain, toPlanFile(f))
+	}
+	for _, f := range dup.cleanup {
+		g.Remove = append(g.Remove, toPlanFile(f))
+	}
+	return g
+}
+
+func (p *plan) duplicates() []duplicate {
+	var duplicates []duplicate
+	for _, g := range p.Groups {
+		dup := duplicate{reference: g.Reference.toFile(), rule: g.Rule, master: g.Master}
+		if g.Keep != nil {
+			dup.kept = g.Keep.toFile()
+		}
+		for _, pf := range g.Retain {
+			dup.retained = append(dup.retained, pf.toFile())
+		}
+		for _, pf := range g.Remove {
+			dup.cleanup = append(dup.cleanup, pf.toFile())
+		}
+		duplicates = append(duplicates, dup)
+	}
+	return duplicates
+}
+
+func savePlan(path string, p *plan) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return fmt.Errorf("writing plan %s: %w", path, err)
+	}
+	return nil
+}
+
+// planWriter writes a plan one group at a time, producing the same JSON
+// document savePlan would, so --stream never holds every group in memory.
+type planWriter struct {
+	f      *os.File
+	groups int
+}
+
+func createPlanWriter(path string, header *plan) (*planWriter, error) {
+	data, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// Groups is the last field; reopen its empty array for appending
+	head, ok := bytes.CutSuffix(data, []byte("[]\n}"))
+	if !ok {
+		return nil, fmt.Errorf("unexpected plan encoding")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(append(head, '[')); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing plan %s: %w", path, err)
+	}
+	return &planWriter{f: f}, nil
+}
+
+func (w *planWriter) add(g planGroup) error {
+	data, err := json.MarshalIndent(g, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n    "
+	if w.groups == 0 {
+		sep = "\n    "
+	}
+	w.groups++
+	_, err = w.f.WriteString(sep + string(data))
+	return err
+}
+
+func (w *planWriter) close() error {
+	tail := "]\n}\n"
+	if w.groups > 0 {
+		tail = "\n  ]\n}\n"
+	}
+	_, err := w.f.WriteString(tail)
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func loadPlan(path string) (*plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("reading plan %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// sealPlan hashes the planned files the analysis left unhashed (the path
+// modes never read content), so apply can check every file against its
+// content and not just its size and mtime.
+func sealPlan(duplicates []duplicate, cfg *config, outFile *os.File) {
+	var todo []*file
+	seen := make(map[*file]bool)
+	for _, dup := range duplicates {
+		files := append([]*file{dup.reference}, dup.retained...)
+		if dup.kept != nil {
+			files = append(files, dup.kept)
+		}
+		for _, f := range append(files, dup.cleanup...) {
+			if f.hash == "" && !seen[f] {
+				seen[f] = true
+				todo = append(todo, f)
+			}
+		}
+	}
+	reportHashFailures(hashFiles(todo, cfg.window, cfg.hashWorkers), outFile)
+}
+
+// staleError is drift since the plan: a changed size, mtime or content.
+// --force-stale overrides only this; a file that is gone or unreadable is
+// never trusted.
+type staleError struct {
+	reason string
+}
+
+func (e staleError) Error() string { return e.reason }
+
+// verifyFile checks that a planned file still has the recorded size and
+// mtime and, when a hash was recorded, the same content. Drift is
+// reported as a staleError; a missing or unreadable file as its own error.
+func verifyFile(f *file, window hashWindow) error {
+	info, err := os.Stat(f.abs)
+	if err != nil {
+		return err
+	}
+	if info.Size() != f.size {
+		return staleError{fmt.Sprintf("size changed (%d -> %d)", f.size, info.Size())}
+	}
+	if !f.modTime.IsZero() && !info.ModTime().Equal(f.modTime) {
+		return staleError{fmt.Sprintf("modified since the plan (%s)", info.ModTime().Local().Format("2006-01-02 15:04:05"))}
+	}
+	if f.hash == "" {
+		return nil
+	}
+	h, err := computeHash(f.abs, window)
+	if err != nil {
+		return err
+	}
+	if h != f.hash {
+		return staleError{"content changed"}
+	}
+	return nil
+}
+
+// forcible reports whether --force-stale may override err from verifyFile.
+func forcible(err error, forceStale bool) bool {
+	var stale staleError
+	return forceStale && errors.As(err, &stale)
+}
+
+// verifyPlan drops groups whose reference changed and removals whose file
+// changed since the plan was made. It returns how many files were dropped.
+// With forceStale files that merely changed are reported but kept; a
+// missing or unreadable reference still drops its group, and a missing or
+// unreadable cleanup file is still skipped.
+func verifyPlan(duplicates []duplicate, window hashWindow, forceStale bool, outFile *os.File) ([]duplicate, int) {
+	var verified []duplicate
+	dropped := 0
+	for _, dup := range duplicates {
+		if err := verifyFile(dup.reference, window); err != nil {
+			if !forcible(err, forceStale) {
+				output(outFile, fmt.Sprintf("  Skipping group, reference %s: %v", dup.reference.abs, err))
+				dropped += len(dup.cleanup)
+				continue
+			}
+			output(outFile, fmt.Sprintf("  Stale reference kept (--force-stale) %s: %v", dup.reference.abs, err))
+		}
+		var remaining []*file
+		for _, f := range dup.cleanup {
+			if err := verifyFile(f, window); err != nil {
+				if !forcible(err, forceStale) {
+					output(outFile, fmt.Sprintf("  Skipping %s: %v", f.abs, err))
+					dropped++
+					continue
+				}
+				output(outFile, fmt.Sprintf("  Stale file kept (--force-stale) %s: %v", f.abs, err))
+			}
+			remaining = append(remaining, f)
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			verified = append(verified, dup)
+		}
+	}
+	return verified, dropped
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	savePath, _ := cmd.Flags().GetString("save-plan")
+	cfg, err := parseConfig(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	var outFile *os.File
+	if cfg.outPath != "" {
+		outFile, err = os.Create(cfg.outPath)
+		if err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+
+	if cfg.stream {
+		return runPlanStream(cfg, savePath, outFile)
+	}
+
+	duplicates, err := analyze(cfg, outFile)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		sealPlan(duplicates, cfg, outFile)
+		output(outFile, "\n=== PLAN ===")
+		if _, err := processDuplicates(duplicates, true, false, confirmPolicy{}, cfg.moveTo, cfg.trash, false, outFile); err != nil {
+			return err
+		}
+		if !cfg.keepEmptyDirs {
+			output(outFile, "\n=== Empty Directory Preview ===")
+			removeEmptyDirs(cfg.cleanup, plannedRemovals(duplicates), true, outFile)
+		}
+	}
+
+	if err := savePlan(savePath, newPlan(cfg, duplicates)); err != nil {
+		return err
+	}
+	output(outFile, fmt.Sprintf("\nSaved plan with %d groups to %s", len(duplicates), savePath))
+	return nil
+}
+
+// runPlanStream writes each group to the plan file as it is found.
+func runPlanStream(cfg *config, savePath string, outFile *os.File) error {
+	w, err := createPlanWriter(savePath, planHeader(cfg))
+	if err != nil {
+		return err
+	}
+
+	output(outFile, "\n=== PLAN (streaming) ===")
+	var writeErr error
+	files := 0
+	err = analyzeStream(cfg, outFile, func(dup duplicate) {
+		if writeErr != nil {
+			return
+		}
+		sealPlan([]duplicate{dup}, cfg, outFile)
+		if writeErr = w.add(toPlanGroup(dup)); writeErr != nil {
+			return
+		}
+		files += len(dup.cleanup)
+		printGroup(w.groups, dup, cfg.moveTo, cfg.trash, outFile)
+	})
+	if cerr := w.close(); writeErr == nil {
+		writeErr = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("writing plan %s: %w", savePath, writeErr)
+	}
+
+	output(outFile, fmt.Sprintf("\nSaved plan with %d groups (%d files to remove) to %s", w.groups, files, savePath))
+	return nil
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	planPath, _ := cmd.Flags().GetString("plan")
+	yes, _ := cmd.Flags().GetBool("yes")
+	verify, _ := cmd.Flags().GetBool("verify")
+	forceStale, _ := cmd.Flags().GetBool("force-stale")
+	forceUnverified, _ := cmd.Flags().GetBool("force-unverified")
+	outPath, _ := cmd.Flags().GetString("out")
+	verifyMoveHash, _ := cmd.Flags().GetBool("verify-move-hash")
+	summaryPath, _ := cmd.Flags().GetString("summary-json")
+
+	p, err := loadPlan(planPath)
+	if err != nil {
+		return err
+	}
+	if p.window().active() && !forceUnverified {
+		return fmt.Errorf("plan was built with --skip-head-bytes/--skip-tail-bytes; pass --force-unverified to apply it")
+	}
+	if p.Trash && !trashSupported {
+		return errTrashUnsupported
+	}
+
+	var outFile *os.File
+	if outPath != "" {
+		outFile, err = os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+
+	start := time.Now()
+	output(outFile, fmt.Sprintf("Applying plan %s (created %s, mode %s)", planPath, p.Created.Local().Format(time.RFC3339), p.Mode))
+
+	duplicates, withheld := protectExts(p.duplicates(), p.NeverDelete, outFile)
+	reportWithheld(withheld, outFile)
+	if verify {
+		output(outFile, "Verifying planned files are unchanged...")
+		var dropped int
+		duplicates, dropped = verifyPlan(duplicates, p.window(), forceStale, outFile)
+		if dropped > 0 && forceStale {
+			output(outFile, fmt.Sprintf("Dropped %d files that are missing or unreadable, or whose reference is; --force-stale never acts on those", dropped))
+		} else if dropped > 0 {
+			output(outFile, fmt.Sprintf("Dropped %d files that changed since the plan was made (--force-stale acts on them anyway)", dropped))
+		}
+	}
+	if len(duplicates) == 0 {
+		output(outFile, "Nothing to apply.")
+		return nil
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		output(outFile, "\n=== DRY RUN RESULTS ===")
+		if _, err := processDuplicates(duplicates, true, false, confirmPolicy{}, p.MoveTo, p.Trash, false, outFile); err != nil {
+			return err
+		}
+		if !p.KeepEmptyDirs {
+			output(outFile, "\n=== Empty Directory Preview ===")
+			removeEmptyDirs(p.Cleanup, plannedRemovals(duplicates), true, outFile)
+		}
+		return nil
+	}
+
+	// The guard runs on the plan as well, before anything is confirmed
+	cfg, err := planBulkConfig(cmd, p, duplicates)
+	if err != nil {
+		return err
+	}
+	if err := checkBulk(cmd, cfg, duplicates, outFile); err != nil {
+		return err
+	}
+
+	output(outFile, "\n=== DELETION OPERATIONS ===")
+	policy, err := parseConfirmPolicy(cmd)
+	if err != nil {
+		return err
+	}
+	policy.assumeYes = yes
+	summary, err := processDuplicates(duplicates, false, true, policy, p.MoveTo, p.Trash, verifyMoveHash, outFile)
+	if serr := saveSummary(summaryPath, summary, outFile); serr != nil {
+		return errors.Join(err, serr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !p.KeepEmptyDirs {
+		output(outFile, "\n=== Empty Directory Cleanup ===")
+		removeEmptyDirs(p.Cleanup, nil, false, outFile)
+	}
+
+	output(outFile, fmt.Sprintf("\nDone in %v.", time.Since(start)))
+	return nil
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Scan and save a deletion/move plan without touching any files",
+	RunE:  runPlan,
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Execute a plan saved by 'dupekill plan'",
+	RunE:  runApply,
+}
+
+func init() {
+	addScanFlags(planCmd)
+	planCmd.MarkFlagRequired("reference")
+	planCmd.Flags().String("save-plan", "", "file to write the plan to (required)")
+	planCmd.MarkFlagRequired("save-plan")
+
+	applyCmd.Flags().String("plan", "", "plan file to execute (required)")
+	applyCmd.Flags().Bool("yes", false, "do not prompt for confirmation")
+	applyCmd.Flags().Bool("verify", true, "re-check sizes, mtimes and hashes before acting; changed files are skipped")
+	applyCmd.Flags().Bool("force-stale", false, "act on files that changed since the plan was made, after reporting them")
+	applyCmd.Flags().Bool("force-unverified", false, "allow applying a plan built with --skip-head-bytes/--skip-tail-bytes")
+	applyCmd.Flags().Bool("verify-move-hash", false, "when a move falls back to copy, also compare hashes before removing the source")
+	applyCmd.Flags().String("out", "", "output report file")
+	applyCmd.Flags().String("summary-json", "", "write what happened (counts, bytes, per-file outcomes and failures) as JSON to this file")
+	addConfirmFlags(applyCmd)
+	addBulkFlags(applyCmd)
+	applyCmd.MarkFlagRequired("plan")
+}
@@ -0,0 +1,120 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// progressiveHash hashes the reference and cleanup files in stages, so a
+// file is only read in full when a file on the other side could still
+// match it: first files are grouped by size (free, from the scan), then
+// same-size candidates get a partial fingerprint of their first and last
+// 64 KiB, and only files whose fingerprint is shared are hashed in full.
+// Files of at most 128 KiB skip the fingerprint, which would read them
+// whole anyway, and are hashed in full once.
+// In path+hash and hash+name modes the path or name is part of every
+// stage's key. Files pruned along the way keep an empty hash, so they
+// match nothing, exactly as if full hashes had differed.
+func progressiveHash(referenceFiles, cleanupFiles []*file, mode Mode, window hashWindow, workers int, out *os.File) []hashFailure {
+	prefix := func(f *file) string {
+		switch mode {
+		case ModePathHash:
+			return f.rel + "|"
+		case ModeHashName:
+			return filepath.Base(f.rel) + "|"
+		}
+		return ""
+	}
+	bySize := func(f *file) string { return fmt.Sprintf("%s%d", prefix(f), f.size) }
+	total := len(referenceFiles) + len(cleanupFiles)
+	ref, cleanup := shared(referenceFiles, cleanupFiles, bySize)
+	output(out, fmt.Sprintf("Size grouping left %d of %d files as candidates", len(ref)+len(cleanup), total))
+
+	// A partial fingerprint covers bytes a --skip-head/tail-bytes window
+	// ignores, and --preview is the fingerprint itself
+	var failed []hashFailure
+	if !window.active() && window.preview == 0 {
+		smallRef, largeRef := splitSmall(ref)
+		smallCleanup, largeCleanup := splitSmall(cleanup)
+		candidates := append(append([]*file(nil), largeRef...), largeCleanup...)
+		failed = hashFiles(candidates, hashWindow{preview: previewBytes}, workers)
+		n := len(ref) + len(cleanup)
+		largeRef, largeCleanup = shared(largeRef, largeCleanup, func(f *file) string {
+			if f.hash == "" {
+				return ""
+			}
+			return prefix(f) + f.hash
+		})
+		for _, f := range candidates {
+			f.hash = ""
+		}
+		ref, cleanup = append(smallRef, largeRef...), append(smallCleanup, largeCleanup...)
+		output(out, fmt.Sprintf("Partial hashes left %d of %d candidates to hash in full", len(ref)+len(cleanup), n))
+	}
+
+	failed = append(failed, hashFiles(ref, window, workers)...)
+	return append(failed, hashFiles(cleanup, window, workers)...)
+}
+
+// shared keeps the files on each side whose key, if not empty, also occurs
+// on the other side.
+func shared(ref, cleanup []*file, key func(*file) string) ([]*file, []*file) {
+	inRef := make(map[string]bool, len(ref))
+	for _, f := range ref {
+		if k := key(f); k != "" {
+			inRef[k] = true
+		}
+	}
+	inCleanup := make(map[string]bool)
+	var keptCleanup []*file
+	for _, f := range cleanup {
+		if k := key(f); inRef[k] {
+			inCleanup[k] = true
+			keptCleanup = append(keptCleanup, f)
+		}
+	}
+	var keptRef []*file
+	for _, f := range ref {
+		if inCleanup[key(f)] {
+			keptRef = append(keptRef, f)
+		}
+	}
+	return keptRef, keptCleanup
+}
+
+// splitSmall separates the files a partial fingerprint would read in full
+// from the rest.
+func splitSmall(files []*file) (small, large []*file) {
+	for _, f := range files {
+		if f.size <= 2*previewBytes {
+			small = append(small, f)
+		} else {
+			large = append(large, f)
+		}
+	}
+	return small, large
+}
+
+// prunePartial is the partial-fingerprint stage of progressiveHash for a
+// single tree: it returns, in their original order, the small files and
+// the files whose fingerprint another file shares, with their hashes
+// cleared for the full pass.
+func prunePartial(files []*file, workers int) ([]*file, []hashFailure) {
+	_, large := splitSmall(files)
+	failed := hashFiles(large, hashWindow{preview: previewBytes}, workers)
+	count := make(map[string]int)
+	for _, f := range large {
+		if f.hash != "" {
+			count[f.hash]++
+		}
+	}
+	var kept []*file
+	for _, f := range files {
+		if f.size <= 2*previewBytes || count[f.hash] > 1 {
+			kept = append(kept, f)
+		}
+		f.hash = ""
+	}
+	return kept, failed
+}
@@ -0,0 +1,66 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseExts normalizes --never-delete-ext values to lower case with a
+// leading dot, so "JPG", ".jpg" and "jpg" all name the same type.
+func parseExts(values []string) ([]string, error) {
+	var exts []string
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || v == "." {
+			return nil, fmt.Errorf("--never-delete-ext needs an extension such as .raw")
+		}
+		if !strings.HasPrefix(v, ".") {
+			v = "." + v
+		}
+		exts = append(exts, v)
+	}
+	return exts, nil
+}
+
+// protectExts is the last filter before anything is reported or removed:
+// cleanup files whose extension is in exts are taken off the delete list
+// and reported as protected. Groups left with nothing to remove are
+// dropped. It returns the remaining groups and how many deletions were
+// withheld.
+func protectExts(duplicates []duplicate, exts []string, outFile *os.File) ([]duplicate, int) {
+	if len(exts) == 0 {
+		return duplicates, 0
+	}
+	protected := make(map[string]bool, len(exts))
+	for _, e := range exts {
+		protected[e] = true
+	}
+
+	var result []duplicate
+	withheld := 0
+	for _, dup := range duplicates {
+		var remaining []*file
+		for _, f := range dup.cleanup {
+			if protected[strings.ToLower(filepath.Ext(f.abs))] {
+				output(outFile, fmt.Sprintf("Protected by extension: %s", f.abs))
+				withheld++
+				continue
+			}
+			remaining = append(remaining, f)
+		}
+		if len(remaining) > 0 {
+			dup.cleanup = remaining
+			result = append(result, dup)
+		}
+	}
+	return result, withheld
+}
+
+// reportWithheld prints how many deletions --never-delete-ext prevented.
+func reportWithheld(withheld int, outFile *os.File) {
+	if withheld > 0 {
+		output(outFile, fmt.Sprintf("Withheld %d deletions protected by --never-delete-ext", withheld))
+	}
+}
@@ -0,0 +1,118 @@
+package dupekill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// sameDir reports whether a and b name the same directory.
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// findSelfDuplicates groups files of a single tree by content. In each
+// group the survivor is the file whose base name matches the
+// highest-priority --prefer pattern or, failing that, the one picked by
+// the --master-rule list, or else the first by path;
+// it becomes the group's reference and the rest are cleanup. A file is
+// never considered a duplicate of itself.
+func findSelfDuplicates(files []*file, window hashWindow, workers int, prefer []*regexp.Regexp, masterRules []string, progressive bool, outFile *os.File) []duplicate {
+	output(outFile, "Finding duplicates within the tree using hash mode...")
+
+	// Only files sharing a size can share content
+	bySize := make(map[int64][]*file)
+	for _, f := range files {
+		bySize[f.size] = append(bySize[f.size], f)
+	}
+	var candidates []*file
+	for _, f := range files { // keep path order
+		if len(bySize[f.size]) > 1 {
+			candidates = append(candidates, f)
+		}
+	}
+	var failed []hashFailure
+	if progressive && !window.active() && window.preview == 0 {
+		n := len(candidates)
+		candidates, failed = prunePartial(candidates, workers)
+		output(outFile, fmt.Sprintf("Partial hashes left %d of %d same-size files to hash in full", len(candidates), n))
+	}
+	if window.active() {
+		output(outFile, fmt.Sprintf("Computing file hashes (UNVERIFIED: ignoring first %d and last %d bytes)...", window.head, window.tail))
+	} else {
+		output(outFile, "Computing file hashes...")
+	}
+	reportHashFailures(append(failed, hashFiles(candidates, window, workers)...), outFile)
+
+	byHash := make(map[string][]*file)
+	var order []string
+	for _, f := range candidates {
+		if f.hash == "" {
+			continue
+		}
+		if _, seen := byHash[f.hash]; !seen {
+			order = append(order, f.hash)
+		}
+		byHash[f.hash] = append(byHash[f.hash], f)
+	}
+
+	var result []duplicate
+	for _, h := range order {
+		group := byHash[h]
+		if len(group) < 2 {
+			continue
+		}
+		survivor, rule := group[0], ""
+	patterns:
+		for _, re := range prefer {
+			for _, f := range group {
+				if re.MatchString(filepath.Base(f.abs)) {
+					survivor, rule = f, re.String()
+					break patterns
+				}
+			}
+		}
+		master := ""
+		if rule == "" && len(masterRules) > 0 {
+			survivor, master = pickMaster(group, masterRules)
+		}
+		dup := duplicate{reference: survivor, rule: rule, master: master}
+		for _, f := range group {
+			if f != survivor {
+				dup.cleanup = append(dup.cleanup, f)
+			}
+		}
+		result = append(result, dup)
+	}
+
+	output(outFile, fmt.Sprintf("Found %d duplicate groups", len(result)))
+	return result
+}
+
+// analyzeSelf is analyze for --exclude-reference-self: one tree is both
+// reference and cleanup.
+func analyzeSelf(cfg *config, outFile *os.File) ([]duplicate, error) {
+	output(outFile, fmt.Sprintf("Scanning tree: %s", cfg.reference))
+	files, stats, err := scanTree(cfg.reference, cfg.includeHidden)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", cfg.reference, err)
+	}
+	stats.report(outFile)
+	output(outFile, fmt.Sprintf("Found %d files", len(files)))
+	cfg.treeFiles = map[string]int{treeRoot(cfg.reference): len(files)}
+
+	if !cfg.followSymlinks {
+		var skipped int
+		files, skipped = dropSymlinks(files)
+		if skipped > 0 {
+			output(outFile, fmt.Sprintf("Skipped %d symlinks; use --follow-symlinks to include them", skipped))
+		}
+	}
+	return findSelfDuplicates(files, cfg.window, cfg.hashWorkers, cfg.prefer, cfg.masterRules, !cfg.noProgressive, outFile), nil
+}
@@ -0,0 +1,65 @@
+package dupekill
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runSummary is the machine-readable record of what a real run did,
+// written to --summary-json. It is built by processDuplicates from the
+// outcome of each file, so it reflects what happened rather than what was
+// planned.
+type runSummary struct {
+	Finished      time.Time      `json:"finished"`
+	Action        string         `json:"action"` // delete | move | trash | relink
+	MoveTo        string         `json:"move_to,omitempty"`
+	Aborted       bool           `json:"aborted,omitempty"` // confirmation declined; nothing touched
+	Groups        int            `json:"groups"`
+	FilesPlanned  int            `json:"files_planned"`
+	FilesRemoved  int            `json:"files_removed"`
+	FilesFailed   int            `json:"files_failed"`
+	FilesRenamed  int            `json:"files_renamed,omitempty"` // moved under a new name to avoid a collision
+	FilesInUse    int            `json:"files_in_use,omitempty"`  // open in another program; skipped, not failed
+	BytesRemoved  int64          `json:"bytes_removed"`
+	BytesFreed    *int64         `json:"bytes_freed,omitempty"` // delete only, where hardlinks are detected
+	GroupOutcomes []groupOutcome `json:"group_outcomes"`
+	Directories   []dirOutcome   `json:"directories,omitempty"` // --dir-level: directories removed as a whole
+}
+
+type groupOutcome struct {
+	Reference string        `json:"reference"`
+	Files     []fileOutcome `json:"files"`
+}
+
+// fileOutcome is what happened to one cleanup copy.
+type fileOutcome struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Status string `json:"status"`         // deleted | moved | trashed | relinked | in_use | left_in_place | failed
+	Dest   string `json:"dest,omitempty"` // moved: where the file went
+	Error  string `json:"error,omitempty"`
+}
+
+// saveSummary writes s to path for --summary-json. Nothing is written when
+// the flag is unset or no real run took place.
+func saveSummary(path string, s *runSummary, outFile *os.File) error {
+	if path == "" || s == nil {
+		return nil
+	}
+	if err := writeSummary(path, s); err != nil {
+		return fmt.Errorf("writing --summary-json: %w", err)
+	}
+	output(outFile, fmt.Sprintf("Summary written to %s", path))
+	return nil
+}
+
+// writeSummary saves s as indented JSON to path.
+func writeSummary(path string, s *runSummary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,7 @@
+package dupekill
+
+import "errors"
+
+// errTrashUnsupported is returned by trashFile on platforms without a
+// recycle bin implementation. Duplicates are never deleted in its place.
+var errTrashUnsupported = errors.New("--trash is not supported on this platform")
@@ -0,0 +1,46 @@
+//go:build darwin
+
+package dupekill
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const trashSupported = true
+
+// trashFile moves path into ~/.Trash under a free name. Files on another
+// volume than the home directory are not moved.
+func trashFile(path string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("locating the trash: %w", err)
+	}
+	dir := filepath.Join(home, ".Trash")
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = stem + " " + strconv.Itoa(n) + ext
+		}
+		dest := filepath.Join(dir, name)
+		if _, err := os.Lstat(dest); err == nil {
+			continue
+		}
+		if err := os.Rename(path, dest); err != nil {
+			if errors.Is(err, syscall.EXDEV) {
+				return fmt.Errorf("not on the same volume as the trash at %s", dir)
+			}
+			return err
+		}
+		return nil
+	}
+}
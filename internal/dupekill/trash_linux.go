@@ -0,0 +1,91 @@
+//go:build linux
+
+package dupekill
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const trashSupported = true
+
+// trashFile moves path into the freedesktop.org home trash
+// ($XDG_DATA_HOME/Trash) with a .trashinfo record, so file managers can
+// restore it. Files on another filesystem than the trash are not moved;
+// copying them would defeat the point of a cheap, recoverable removal.
+func trashFile(path string) error {
+	dir, err := trashDir()
+	if err != nil {
+		return err
+	}
+	filesDir := filepath.Join(dir, "files")
+	infoDir := filepath.Join(dir, "info")
+	for _, d := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return err
+		}
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+
+	// Reserve a free name by creating its .trashinfo first, as the spec asks
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = base + "." + strconv.Itoa(n)
+		}
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, werr := f.WriteString(info)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			os.Remove(infoPath)
+			return werr
+		}
+
+		dest := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(dest); err == nil {
+			os.Remove(infoPath)
+			continue
+		}
+		if err := os.Rename(abs, dest); err != nil {
+			os.Remove(infoPath)
+			if errors.Is(err, syscall.EXDEV) {
+				return fmt.Errorf("not on the same filesystem as the trash at %s", dir)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+func trashDir() (string, error) {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating the trash: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package dupekill
+
+const trashSupported = false
+
+func trashFile(path string) error {
+	return errTrashUnsupported
+}
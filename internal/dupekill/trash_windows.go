@@ -0,0 +1,93 @@
+//go:build windows
+
+package dupekill
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const trashSupported = true
+
+var (
+	shell32                = syscall.NewLazyDLL("shell32.dll")
+	procSHFileOperationW   = shell32.NewProc("SHFileOperationW")
+	procSHQueryRecycleBinW = shell32.NewProc("SHQueryRecycleBinW")
+)
+
+// shQueryRBInfo mirrors SHQUERYRBINFO.
+type shQueryRBInfo struct {
+	cbSize      uint32
+	i64Size     int64
+	i64NumItems int64
+}
+
+// shFileOpStruct mirrors SHFILEOPSTRUCTW.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+const (
+	foDelete          = 0x3
+	fofSilent         = 0x4
+	fofNoConfirmation = 0x10
+	fofAllowUndo      = 0x40
+	fofNoErrorUI      = 0x400
+)
+
+// trashFile sends path to the Recycle Bin. Drives without one (e.g. network
+// shares) are checked first and reported as failures, since SHFileOperation
+// would otherwise delete the file permanently without asking.
+func trashFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := checkRecycleBin(abs); err != nil {
+		return err
+	}
+	// pFrom is a list of paths ended by an extra NUL
+	from, err := syscall.UTF16FromString(abs)
+	if err != nil {
+		return err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofSilent | fofNoConfirmation | fofAllowUndo | fofNoErrorUI,
+	}
+	ret, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 {
+		return fmt.Errorf("SHFileOperation failed with code 0x%x", ret)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return fmt.Errorf("moving to the Recycle Bin was cancelled")
+	}
+	return nil
+}
+
+// checkRecycleBin fails unless the volume holding abs has a Recycle Bin.
+func checkRecycleBin(abs string) error {
+	root := filepath.VolumeName(abs) + `\`
+	p, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return err
+	}
+	info := shQueryRBInfo{cbSize: uint32(unsafe.Sizeof(shQueryRBInfo{}))}
+	ret, _, _ := procSHQueryRecycleBinW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&info)))
+	if ret != 0 {
+		return fmt.Errorf("%s has no Recycle Bin (code 0x%x); not deleting permanently", root, ret)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package junksweep
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// criticalDirs lists system locations that junksweep refuses to scan.
+func criticalDirs() []string {
+	switch runtime.GOOS {
+	case "windows": // %TEMP% only
+		var dirs []string
+		for _, env := range []string{"WINDIR", "ProgramFiles", "ProgramFiles(x86)", "ProgramData"} {
+			if v := os.Getenv(env); v != "" {
+				dirs = append(dirs, v)
+			}
+		}
+		return dirs
+	case "darwin":
+		return []string{"/System", "/Library", "/Applications", "/usr", "/bin", "/sbin", "/etc", "/private", "/var"}
+	default:
+		return []string{"/etc", "/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/boot", "/dev", "/proc", "/sys", "/var", "/opt", "/run", "/snap"}
+	}
+}
+
+// tempDirs lists scratch locations inside criticalDirs that may be swept
+// all the same: the OS temp dir (on macOS under /private/var/folders) and
+// /var/tmp.
+func tempDirs() []string {
+	dirs := []string{os.TempDir()}
+	switch runtime.GOOS {
+	case "windows": // %TEMP% only
+	case "darwin":
+		dirs = append(dirs, "/private/var/folders", "/private/var/tmp", "/private/tmp")
+	default:
+		dirs = append(dirs, "/var/tmp")
+	}
+	return dirs
+}
+
+// inTempDir reports whether abs is one of tempDirs or inside one. A temp
+// dir that is itself a root or a critical directory (e.g. TMPDIR=/var)
+// exempts nothing.
+func inTempDir(abs string) bool {
+	for _, tmp := range tempDirs() {
+		tmp = filepath.Clean(tmp)
+		if resolved, err := filepath.EvalSymlinks(tmp); err == nil {
+			tmp = resolved
+		}
+		if filepath.Dir(tmp) == tmp || isCritical(tmp) {
+			continue
+		}
+		if samePath(abs, tmp) || isWithin(abs, tmp) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCritical(dir string) bool {
+	for _, sys := range criticalDirs() {
+		if samePath(dir, filepath.Clean(sys)) {
+			return true
+		}
+	}
+	return false
+}
+
+// criticalReason explains why dir is unsafe to sweep, or returns "" if it
+// is not a filesystem root or inside a known system directory (temp dirs
+// excepted).
+func criticalReason(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	if filepath.Dir(abs) == abs {
+		return abs + " is a filesystem root"
+	}
+	if inTempDir(abs) {
+		return ""
+	}
+
+	for _, sys := range criticalDirs() {
+		sys = filepath.Clean(sys)
+		if samePath(abs, sys) || isWithin(abs, sys) {
+			return abs + " is inside system directory " + sys
+		}
+	}
+	return ""
+}
+
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+func isWithin(path, dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	if runtime.GOOS == "windows" {
+		return strings.HasPrefix(strings.ToLower(path), strings.ToLower(prefix))
+	}
+	return strings.HasPrefix(path, prefix)
+}
@@ -3,11 +3,14 @@ package junksweep
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -23,26 +26,37 @@ var deletePatterns = []string{
 	".DS_Store",
 }
 
-// Checks if a file matches any of the delete patterns
-func matchesDeletePattern(name string) bool {
+// junkFile is a scan hit along with the pattern that caught it
+type junkFile struct {
+	path    string
+	pattern string
+	size    int64
+	modTime time.Time
+}
+
+// Returns the first delete pattern the file name matches
+func matchDeletePattern(name string) (string, bool) {
 	for _, pattern := range deletePatterns {
 		if strings.Contains(name, pattern) {
-			return true
+			return pattern, true
 		}
 	}
-	return false
+	return "", false
 }
 
-// Concurrently scan directories for files to delete
-func scanFilesConcurrent(baseDir string, workers int) ([]string, error) {
+// Concurrently scan directories for files to delete. Also returns the
+// total number of files traversed. maxDepth limits how many directory
+// levels are scanned, counting baseDir itself as 1 (0 = unlimited).
+func scanFilesConcurrent(baseDir string, workers int, maxDepth int, m matcher) ([]junkFile, int64, error) {
 	if workers <= 0 {
 		workers = runtime.NumCPU()
 	}
 
 	dirCh := make(chan string, 100)
-	fileCh := make(chan string, 1000)
+	fileCh := make(chan junkFile, 1000)
 
 	var wg sync.WaitGroup
+	var scanned atomic.Int64
 
 	// Worker: only consumes dirs, scans them, sends matching files
 	for i := 0; i < workers; i++ {
@@ -59,8 +73,28 @@ func scanFilesConcurrent(baseDir string, workers int) ([]string, error) {
 						// Enqueue subdirs — but who does this?
 						// → Not the worker! We'll do it in the feeder.
 						// So we *cannot* do it here.
-					} else if matchesDeletePattern(entry.Name()) {
-						fileCh <- filepath.Join(dir, entry.Name())
+						continue
+					}
+					scanned.Add(1)
+					target := entry.Name()
+					if m.matchPath {
+						if rel, err := filepath.Rel(baseDir, filepath.Join(dir, target)); err == nil {
+							target = rel
+						}
+					}
+					if pattern, ok := m.match(target); ok {
+						var size int64
+						var modTime time.Time
+						if info, err := entry.Info(); err == nil {
+							size = info.Size()
+							modTime = info.ModTime()
+						}
+						fileCh <- junkFile{
+							path:    filepath.Join(dir, entry.Name()),
+							pattern: pattern,
+							size:    size,
+							modTime: modTime,
+						}
 					}
 				}
 			}
@@ -71,22 +105,29 @@ func scanFilesConcurrent(baseDir string, workers int) ([]string, error) {
 	go func() {
 		defer close(dirCh)
 
-		dirs := []string{baseDir}
+		type queued struct {
+			path  string
+			depth int
+		}
+		dirs := []queued{{baseDir, 1}}
 		for len(dirs) > 0 {
 			current := dirs[0]
 			dirs = dirs[1:]
 
 			// Send to workers for file scanning
-			dirCh <- current
+			dirCh <- current.path
+			if maxDepth > 0 && current.depth >= maxDepth {
+				continue
+			}
 
 			// Now, read it ourselves to find subdirs (to avoid worker writing to dirCh)
-			entries, err := os.ReadDir(current)
+			entries, err := os.ReadDir(current.path)
 			if err != nil {
 				continue
 			}
 			for _, entry := range entries {
 				if entry.IsDir() {
-					dirs = append(dirs, filepath.Join(current, entry.Name()))
+					dirs = append(dirs, queued{filepath.Join(current.path, entry.Name()), current.depth + 1})
 				}
 			}
 		}
@@ -98,20 +139,80 @@ func scanFilesConcurrent(baseDir string, workers int) ([]string, error) {
 		close(fileCh)
 	}()
 
-	var files []string
+	var files []junkFile
 	for f := range fileCh {
 		files = append(files, f)
 	}
 
-	return files, nil
+	return files, scanned.Load(), nil
+}
+
+// Human-readable byte count
+func humanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// Write a per-pattern breakdown of counts and bytes, in deletePatterns order
+func writeSummary(w io.Writer, files []junkFile) {
+	counts := make(map[string]int)
+	sizes := make(map[string]int64)
+	var total int64
+	for _, f := range files {
+		counts[f.pattern]++
+		sizes[f.pattern] += f.size
+		total += f.size
+	}
+
+	fmt.Fprintln(w, "\nSummary by pattern:")
+	for _, pattern := range deletePatterns {
+		if counts[pattern] == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-12s %6d files  %10s\n", pattern, counts[pattern], humanSize(sizes[pattern]))
+	}
+	fmt.Fprintf(w, "  %-12s %6d files  %10s\n", "total", len(files), humanSize(total))
+	writeHighlights(w, files)
+}
+
+// Point out the largest and the oldest match, the usual surprises
+func writeHighlights(w io.Writer, files []junkFile) {
+	var largest, oldest *junkFile
+	for i := range files {
+		f := &files[i]
+		if largest == nil || f.size > largest.size {
+			largest = f
+		}
+		if !f.modTime.IsZero() && (oldest == nil || f.modTime.Before(oldest.modTime)) {
+			oldest = f
+		}
+	}
+	if largest == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "\nLargest: %s (%s)\n", largest.path, humanSize(largest.size))
+	if oldest != nil {
+		days := int(time.Since(oldest.modTime).Hours() / 24)
+		fmt.Fprintf(w, "Oldest:  %s (%d days)\n", oldest.path, days)
+	}
 }
 
 // Output files either to console or to a file
-func outputFiles(files []string, outPath string) error {
+func outputFiles(files []junkFile, outPath string) error {
 	if outPath == "" {
 		for _, f := range files {
-			fmt.Println(f)
+			fmt.Println(f.path)
 		}
+		writeSummary(os.Stdout, files)
 		return nil
 	}
 
@@ -122,18 +223,65 @@ func outputFiles(files []string, outPath string) error {
 	defer outFile.Close()
 
 	for _, f := range files {
-		fmt.Fprintln(outFile, f)
+		fmt.Fprintln(outFile, f.path)
 	}
+	writeSummary(outFile, files)
+	writeSummary(os.Stdout, files)
 	return nil
 }
 
+// deleteResult tallies the outcome of a deletion pass
+type deleteResult struct {
+	deleted  int
+	failed   int
+	readOnly int // skipped because read-only and --clear-readonly not set
+}
+
+// Outcome of removing a single file
+type removeOutcome int
+
+const (
+	removed removeOutcome = iota
+	removeFailed
+	skippedReadOnly
+)
+
+// Remove a file, handling read-only files. On Windows os.Remove refuses to
+// delete files with the read-only attribute; os.Chmod clears that attribute
+// there and the write bit elsewhere. Any other failure is reported as is,
+// and a file whose retry fails gets its old mode back.
+func removeFile(path string, clearReadOnly bool) (removeOutcome, error) {
+	err := os.Remove(path)
+	if err == nil {
+		return removed, nil
+	}
+	info, statErr := os.Lstat(path)
+	if statErr != nil || info.Mode()&os.ModeSymlink != 0 || !blockedByReadOnly(path, info, err) {
+		return removeFailed, err
+	}
+	if !clearReadOnly {
+		return skippedReadOnly, err
+	}
+	mode := info.Mode().Perm()
+	if err := os.Chmod(path, mode|0200); err != nil {
+		return removeFailed, err
+	}
+	if err := os.Remove(path); err != nil {
+		os.Chmod(path, mode)
+		return removeFailed, err
+	}
+	return removed, nil
+}
+
 // Delete files concurrently
-func deleteFilesConcurrent(files []string, workers int) {
+func deleteFilesConcurrent(files []junkFile, workers int, clearReadOnly bool) deleteResult {
 	if workers <= 0 {
 		workers = runtime.NumCPU()
 	}
 
 	fileCh := make(chan string, len(files))
+	var res deleteResult
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 
 	for i := 0; i < workers; i++ {
@@ -141,16 +289,29 @@ func deleteFilesConcurrent(files []string, workers int) {
 		go func() {
 			defer wg.Done()
 			for f := range fileCh {
-				os.Remove(f) // ignore errors for now
+				outcome, err := removeFile(f, clearReadOnly)
+				mu.Lock()
+				switch outcome {
+				case removed:
+					res.deleted++
+				case skippedReadOnly:
+					res.readOnly++
+					fmt.Println("Skipped read-only:", f)
+				default:
+					res.failed++
+					fmt.Printf("Failed to delete %s: %v\n", f, err)
+				}
+				mu.Unlock()
 			}
 		}()
 	}
 
 	for _, f := range files {
-		fileCh <- f
+		fileCh <- f.path
 	}
 	close(fileCh)
 	wg.Wait()
+	return res
 }
 
 // Cmd is the cobra command for "ds junksweep"
@@ -164,19 +325,49 @@ func init() {
 	Cmd.Flags().StringP("dir", "d", "", "directory to scan (required)")
 	Cmd.Flags().StringP("out", "o", "", "optional file to save list")
 	Cmd.Flags().IntP("workers", "w", 0, "workers (0 = NumCPU)")
+	Cmd.Flags().StringArray("pattern", nil, "glob of files to sweep instead of the built-in junk patterns, e.g. *.tmp (repeatable)")
+	Cmd.Flags().Int("max-depth", 0, "directory levels to sweep, counting --dir as 1: 1 = only files directly in --dir (0 = unlimited)")
+	Cmd.Flags().Bool("match-path", false, "match --pattern against the path relative to --dir (e.g. logs/*.tmp) instead of the file name")
+	Cmd.Flags().String("move-to", "", "move matched files into this quarantine directory (keeping relative paths) instead of deleting")
+	Cmd.Flags().BoolP("yes", "y", false, "delete (or move) without asking; without it, nothing is deleted unless y/yes is typed on stdin")
+	Cmd.Flags().Bool("clear-readonly", false, "clear the read-only attribute and retry instead of skipping such files")
+	Cmd.Flags().Int64("max-files", 500000, "refuse to delete if the scan traverses more files than this")
+	Cmd.Flags().Bool("i-know-what-im-doing", false, "allow sweeping filesystem roots, system directories and huge trees")
 }
 
 func run(cmd *cobra.Command, args []string) error {
 	dir, _ := cmd.Flags().GetString("dir")
 	outPath, _ := cmd.Flags().GetString("out")
 	workers, _ := cmd.Flags().GetInt("workers")
+	maxFiles, _ := cmd.Flags().GetInt64("max-files")
+	override, _ := cmd.Flags().GetBool("i-know-what-im-doing")
+	clearReadOnly, _ := cmd.Flags().GetBool("clear-readonly")
+	moveTo, _ := cmd.Flags().GetString("move-to")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	assumeYes, _ := cmd.Flags().GetBool("yes")
+	patterns, _ := cmd.Flags().GetStringArray("pattern")
+	matchPath, _ := cmd.Flags().GetBool("match-path")
+	maxDepth, _ := cmd.Flags().GetInt("max-depth")
 
 	if dir == "" {
 		return fmt.Errorf("flag -dir is required")
 	}
+	if maxDepth < 0 {
+		return fmt.Errorf("--max-depth must not be negative")
+	}
+	m, err := newMatcher(patterns, matchPath)
+	if err != nil {
+		return err
+	}
+	if reason := criticalReason(dir); reason != "" {
+		if !override {
+			return fmt.Errorf("refusing to sweep: %s (use --i-know-what-im-doing to override)", reason)
+		}
+		fmt.Println("WARNING:", reason)
+	}
 
 	fmt.Println("Scanning directory:", dir)
-	files, err := scanFilesConcurrent(dir, workers)
+	files, scanned, err := scanFilesConcurrent(dir, workers, maxDepth, m)
 	if err != nil {
 		return err
 	}
@@ -188,15 +379,48 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("\nDo you want to delete these %d files? (y/yes): ", len(files))
-	reader := bufio.NewReader(os.Stdin)
-	resp, _ := reader.ReadString('\n')
-	resp = strings.TrimSpace(strings.ToLower(resp))
-	if resp == "y" || resp == "yes" {
-		deleteFilesConcurrent(files, workers)
-		fmt.Println("Deletion complete.")
+	if dryRun {
+		fmt.Println("\nDry-run enabled. No files were deleted.")
+		return nil
+	}
+
+	if maxFiles > 0 && scanned > maxFiles {
+		fmt.Printf("\nWARNING: scan traversed %d files (limit %d).\n", scanned, maxFiles)
+		if !override {
+			fmt.Println("No files were deleted. Raise --max-files or use --i-know-what-im-doing to proceed.")
+			return nil
+		}
+	}
+
+	if !assumeYes {
+		if moveTo != "" {
+			fmt.Printf("\nDo you want to move these %d files to %s? (y/yes): ", len(files), moveTo)
+		} else {
+			fmt.Printf("\nDo you want to delete these %d files? (y/yes): ", len(files))
+		}
+		reader := bufio.NewReader(os.Stdin)
+		resp, err := reader.ReadString('\n')
+		if err != nil && resp == "" {
+			// Closed or empty stdin (e.g. a script without a TTY) never confirms
+			fmt.Println("\nNo answer on stdin. No files were deleted; use --yes to run unattended.")
+			return nil
+		}
+		resp = strings.TrimSpace(strings.ToLower(resp))
+		if resp != "y" && resp != "yes" {
+			fmt.Println("No files were deleted.")
+			return nil
+		}
+	}
+
+	if moveTo != "" {
+		res := moveFilesConcurrent(files, dir, moveTo, workers)
+		fmt.Printf("Quarantine complete: %d moved, %d failed.\n", res.moved, res.failed)
 	} else {
-		fmt.Println("No files were deleted.")
+		res := deleteFilesConcurrent(files, workers, clearReadOnly)
+		fmt.Printf("Deletion complete: %d deleted, %d failed, %d skipped read-only.\n", res.deleted, res.failed, res.readOnly)
+		if res.readOnly > 0 {
+			fmt.Println("Re-run with --clear-readonly to remove read-only files.")
+		}
 	}
 	return nil
 }
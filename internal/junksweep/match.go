@@ -0,0 +1,70 @@
+package junksweep
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matcher decides which files are junk. Without globs it uses the built-in
+// deletePatterns on the base name. With --pattern globs it matches those
+// instead: against the base name, or with --match-path against the path
+// relative to the scan root.
+type matcher struct {
+	globs     []string
+	matchPath bool
+}
+
+func newMatcher(globs []string, matchPath bool) (matcher, error) {
+	if matchPath && len(globs) == 0 {
+		return matcher{}, fmt.Errorf("--match-path needs at least one --pattern; the built-in patterns only apply to file names")
+	}
+	for _, g := range globs {
+		if _, err := path.Match(g, ""); err != nil {
+			return matcher{}, fmt.Errorf("invalid --pattern %q: %w", g, err)
+		}
+	}
+	return matcher{globs: globs, matchPath: matchPath}, nil
+}
+
+// match returns the pattern that target hits. target is the relative path
+// with --match-path and the base name otherwise.
+func (m matcher) match(target string) (string, bool) {
+	if len(m.globs) == 0 {
+		return matchDeletePattern(target)
+	}
+	target = filepath.ToSlash(target)
+	for _, g := range m.globs {
+		if m.matchPath && matchRelPath(g, target) {
+			return g, true
+		}
+		if !m.matchPath {
+			if ok, _ := path.Match(g, target); ok {
+				return g, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchRelPath matches a glob against a slash-separated relative path. A
+// pattern starting with "/" is anchored at the scan root; any other is also
+// tried against each trailing part of the path, so logs/*.tmp matches
+// a/b/logs/x.tmp. As in any glob, * never crosses a "/".
+func matchRelPath(pattern, rel string) bool {
+	if anchored, ok := strings.CutPrefix(pattern, "/"); ok {
+		match, _ := path.Match(anchored, rel)
+		return match
+	}
+	for {
+		if match, _ := path.Match(pattern, rel); match {
+			return true
+		}
+		i := strings.IndexByte(rel, '/')
+		if i < 0 {
+			return false
+		}
+		rel = rel[i+1:]
+	}
+}
@@ -0,0 +1,67 @@
+package junksweep
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/move"
+)
+
+// moveResult tallies the outcome of a quarantine pass
+type moveResult struct {
+	moved  int
+	failed int
+}
+
+// Move src to dst, creating parent directories. Never overwrites; across
+// devices the copy is checked before the source is removed.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return move.File(src, dst, move.Options{})
+}
+
+// Move files concurrently into quarantine, keeping their path relative to
+// baseDir so files with the same name don't collide
+func moveFilesConcurrent(files []junkFile, baseDir, quarantine string, workers int) moveResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	fileCh := make(chan string, len(files))
+	var res moveResult
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range fileCh {
+				rel, err := filepath.Rel(baseDir, f)
+				if err == nil {
+					err = moveFile(f, filepath.Join(quarantine, rel))
+				}
+				mu.Lock()
+				if err != nil {
+					res.failed++
+					fmt.Printf("Failed to move %s: %v\n", f, err)
+				} else {
+					res.moved++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, f := range files {
+		fileCh <- f.path
+	}
+	close(fileCh)
+	wg.Wait()
+	return res
+}
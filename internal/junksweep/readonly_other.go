@@ -0,0 +1,14 @@
+//go:build !unix
+
+package junksweep
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// blockedByReadOnly reports whether removing path failed with err only
+// because of its read-only attribute, which os.Remove refuses on Windows.
+func blockedByReadOnly(path string, info fs.FileInfo, err error) bool {
+	return errors.Is(err, fs.ErrPermission) && info.Mode().Perm()&0200 == 0
+}
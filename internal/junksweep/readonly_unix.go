@@ -0,0 +1,26 @@
+//go:build unix
+
+package junksweep
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+)
+
+// accessWrite is access(2)'s W_OK.
+const accessWrite = 0x2
+
+// blockedByReadOnly reports whether removing path failed with err only
+// because the file lacks its write bit: a permission error, a read-only
+// file and a parent directory we may write to.
+func blockedByReadOnly(path string, info fs.FileInfo, err error) bool {
+	if !errors.Is(err, syscall.EACCES) && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	if info.Mode().Perm()&0200 != 0 {
+		return false
+	}
+	return syscall.Access(filepath.Dir(path), accessWrite) == nil
+}
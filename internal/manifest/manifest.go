@@ -0,0 +1,211 @@
+// Package manifest defines the on-disk inventory of a directory tree shared
+// by ds commands, along with the walker and hasher that produce it.
+//
+// A manifest is a JSON document:
+//
+//	{
+//	  "version": 1,
+//	  "root": "/data/photos",
+//	  "created": "2026-01-01T12:00:00Z",
+//	  "algorithm": "sha256",
+//	  "files": [
+//	    {"path": "2024/img.jpg", "size": 12345, "mtime": "2025-12-31T09:00:00Z", "hash": "ab12..."}
+//	  ]
+//	}
+//
+// Paths are slash-separated and relative to root. "algorithm" and "hash"
+// are omitted when the manifest was built without hashing; "hash" is also
+// omitted for files that could not be read. Readers accept any version up
+// to Version; fields are only ever added, never renamed or removed.
+package manifest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Version is the current manifest format version.
+const Version = 1
+
+// Manifest is an inventory of the regular files under Root.
+type Manifest struct {
+	Version   int       `json:"version"`
+	Root      string    `json:"root"`
+	Created   time.Time `json:"created"`
+	Algorithm string    `json:"algorithm,omitempty"`
+	Files     []Entry   `json:"files"`
+}
+
+// Entry describes a single file.
+type Entry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// Algorithms lists the supported hash algorithm names.
+var Algorithms = []string{"sha256", "sha1", "sha512", "md5"}
+
+// NewHasher returns a constructor for the named hash algorithm.
+func NewHasher(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	case "md5":
+		return md5.New, nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %q (use: sha256, sha1, sha512, md5)", algorithm)
+}
+
+// HashFile returns the hex digest of the file at path.
+func HashFile(path string, newHash func() hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Scan walks root and inventories every regular file. Symlinks are not
+// followed. If algorithm is non-empty each file is hashed using workers
+// goroutines (0 = NumCPU). Root is recorded as an absolute path, so the
+// manifest still locates the tree when read from another directory.
+func Scan(root, algorithm string, workers int) (*Manifest, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var newHash func() hash.Hash
+	if algorithm != "" {
+		if newHash, err = NewHasher(algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Manifest{Version: Version, Root: root, Created: time.Now().UTC(), Algorithm: algorithm}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // unreadable subtree: skip
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		m.Files = append(m.Files, Entry{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	if newHash != nil {
+		hashEntries(root, m.Files, newHash, workers)
+	}
+	return m, nil
+}
+
+// hashEntries fills in Hash for each entry concurrently. Unreadable files
+// are left without a hash.
+func hashEntries(root string, entries []Entry, newHash func() hash.Hash, workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int, len(entries))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				path := filepath.Join(root, filepath.FromSlash(entries[idx].Path))
+				if h, err := HashFile(path, newHash); err == nil {
+					entries[idx].Hash = h
+				}
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Save writes the manifest as indented JSON.
+func (m *Manifest) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.Write(f)
+}
+
+// Write encodes the manifest as indented JSON to w.
+func (m *Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// Load reads a manifest and rejects versions newer than this build knows.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+	if m.Version == 0 {
+		m.Version = 1 // written before the version field existed
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("manifest %s has version %d; this build supports up to %d", path, m.Version, Version)
+	}
+	return &m, nil
+}
+
+// HasHashes reports whether the manifest was built with hashing.
+func (m *Manifest) HasHashes() bool {
+	return m.Algorithm != ""
+}
@@ -0,0 +1,136 @@
+// Package move relocates files for the ds commands that quarantine or
+// collect files instead of deleting them. A rename is tried first; only
+// when the destination is on another device is the file copied, and the
+// source is removed once the copy has been checked against it.
+package move
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrUnverified marks a move whose copy did not match the source; the
+// source is left in place and the copy removed.
+var ErrUnverified = errors.New("copy verification failed")
+
+// Options tunes File.
+type Options struct {
+	// VerifyHash compares the copy's content with the source, not only
+	// its size.
+	VerifyHash bool
+	// BeforeCopy, if set, runs before a cross-device copy; an error
+	// aborts the move with the source untouched.
+	BeforeCopy func(src string) error
+}
+
+// File moves src to dst without overwriting. Rename errors other than a
+// cross-device one are returned as is. Across devices it copies, verifies
+// the copy and only then removes the source; if the source cannot be
+// removed after all the copy is deleted again, so a failed move never
+// leaves a second copy behind.
+func File(src, dst string, opts Options) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("destination %s already exists", dst)
+	}
+	err := os.Rename(src, dst)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+	if opts.BeforeCopy != nil {
+		if err := opts.BeforeCopy(src); err != nil {
+			return err
+		}
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := verifyCopy(src, dst, opts.VerifyHash); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst, preserving the file mode and modification time
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func verifyCopy(src, dst string, verifyHash bool) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnverified, err)
+	}
+	if srcInfo.Size() != dstInfo.Size() {
+		return fmt.Errorf("%w: size %d != %d", ErrUnverified, dstInfo.Size(), srcInfo.Size())
+	}
+	if !verifyHash {
+		return nil
+	}
+
+	srcHash, err := hashFile(src)
+	if err != nil {
+		return err
+	}
+	dstHash, err := hashFile(dst)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnverified, err)
+	}
+	if srcHash != dstHash {
+		return fmt.Errorf("%w: content differs", ErrUnverified)
+	}
+	return nil
+}
+
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package move
+
+import (
+	"errors"
+	"syscall"
+)
+
+// crossDevice reports whether a rename failed because src and dst are on
+// different filesystems.
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package move
+
+import (
+	"errors"
+	"syscall"
+)
+
+const errorNotSameDevice syscall.Errno = 17
+
+// crossDevice reports whether a rename failed because src and dst are on
+// different volumes.
+func crossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
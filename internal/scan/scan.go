@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+func run(cmd *cobra.Command, args []string) error {
+	dir, _ := cmd.Flags().GetString("dir")
+	outPath, _ := cmd.Flags().GetString("out")
+	doHash, _ := cmd.Flags().GetBool("hash")
+	algo, _ := cmd.Flags().GetString("algo")
+	workers, _ := cmd.Flags().GetInt("workers")
+
+	if dir == "" {
+		return fmt.Errorf("flag --dir is required")
+	}
+	if !doHash {
+		algo = ""
+	} else if _, err := manifest.NewHasher(algo); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	m, err := manifest.Scan(dir, algo, workers)
+	if err != nil {
+		return err
+	}
+
+	if outPath == "" {
+		return m.Write(os.Stdout)
+	}
+	if err := m.Save(outPath); err != nil {
+		return err
+	}
+	fmt.Printf("Inventoried %d files in %s to %s in %v\n", len(m.Files), dir, outPath, time.Since(start))
+	return nil
+}
+
+// Cmd is the cobra command for "ds scan"
+var Cmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Inventory a directory tree to a manifest file",
+	RunE:  run,
+}
+
+func init() {
+	Cmd.Flags().StringP("dir", "d", "", "directory to inventory (required)")
+	Cmd.Flags().StringP("out", "o", "", "manifest file to write (default: stdout)")
+	Cmd.Flags().Bool("hash", false, "include a content hash for every file")
+	Cmd.Flags().String("algo", "sha256", "hash algorithm: sha256 | sha1 | sha512 | md5")
+	Cmd.Flags().IntP("workers", "w", 0, "hashing workers (0 = NumCPU)")
+}
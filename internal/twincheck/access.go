@@ -0,0 +1,53 @@
+package twincheck
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// isPermission reports whether err is EACCES or EPERM (ERROR_ACCESS_DENIED
+// on Windows, a permission-denied status over SFTP), as opposed to a
+// directory that vanished or an I/O error.
+func isPermission(err error) bool {
+	return errors.Is(err, fs.ErrPermission)
+}
+
+// deniedPath joins a tree's base and a relative directory for display;
+// remote bases keep their URL form.
+func deniedPath(t *tree, rel string) string {
+	if rel == "." {
+		return t.base
+	}
+	return strings.TrimRight(t.base, `/\`) + string(os.PathSeparator) + rel
+}
+
+// reportDenied prints the directories that could not be listed for lack of
+// permission, so files missing under them are not mistaken for files
+// missing from the tree.
+func reportDenied(outFile *os.File, trees ...*tree) {
+	var paths []string
+	for _, t := range trees {
+		if t == nil {
+			continue
+		}
+		for _, rel := range t.denied {
+			paths = append(paths, deniedPath(t, rel))
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+	noun := "directories were"
+	if len(paths) == 1 {
+		noun = "directory was"
+	}
+	output(outFile, fmt.Sprintf("\n%d %s inaccessible (permission denied) and skipped, not found empty. Re-run elevated (sudo, or an Administrator prompt) to include them:", len(paths), noun))
+	for _, p := range paths {
+		output(outFile, "  "+p)
+	}
+}
@@ -0,0 +1,113 @@
+package twincheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// contentGroup is every path holding one content hash, per tree.
+type contentGroup struct {
+	hash        string
+	pathsA      []string
+	pathsB      []string
+	underPrefix bool // some path lies under --filter-prefix
+}
+
+// compareByContent hashes every file in both trees and groups the paths by
+// content, so identity is decoupled from location: a file moved and
+// renamed shows up as one group with different paths in A and B. Groups
+// whose paths match in both trees are only counted. A storage summary of
+// the overlap follows. It returns the number of groups reported as
+// differences.
+func compareByContent(a, b *tree, opts options) int {
+	hashesA, errsA, hashesB, errsB := hashPair(a, a.allPaths(), b, b.allPaths(), opts)
+
+	groups := make(map[string]*contentGroup)
+	add := func(hashes map[string]string, side func(*contentGroup) *[]string) {
+		for p, h := range hashes {
+			g, ok := groups[h]
+			if !ok {
+				g = &contentGroup{hash: h}
+				groups[h] = g
+			}
+			*side(g) = append(*side(g), p)
+			if opts.filter.match(p) {
+				g.underPrefix = true
+			}
+		}
+	}
+	add(hashesA, func(g *contentGroup) *[]string { return &g.pathsA })
+	add(hashesB, func(g *contentGroup) *[]string { return &g.pathsB })
+
+	var moved, onlyA, onlyB []*contentGroup
+	same := 0
+	for _, g := range groups {
+		if !g.underPrefix {
+			continue
+		}
+		sort.Strings(g.pathsA)
+		sort.Strings(g.pathsB)
+		switch {
+		case len(g.pathsB) == 0:
+			onlyA = append(onlyA, g)
+		case len(g.pathsA) == 0:
+			onlyB = append(onlyB, g)
+		case strings.Join(g.pathsA, "\x00") == strings.Join(g.pathsB, "\x00"):
+			same++
+		default:
+			moved = append(moved, g)
+		}
+	}
+
+	var unreadable []string
+	for side, errs := range map[string]map[string]error{"A": errsA, "B": errsB} {
+		for p, err := range errs {
+			if opts.filter.match(p) {
+				unreadable = append(unreadable, fmt.Sprintf("[%s] %s: %v", side, p, err))
+			}
+		}
+	}
+	sort.Strings(unreadable)
+
+	output(opts.outFile, fmt.Sprintf("\n%d distinct contents are at the same paths in both trees.", same))
+	outputSection(opts.outFile, "Same content, different paths", groupLines(moved), opts.limit)
+	if len(onlyA) > 0 {
+		outputSection(opts.outFile, "Content only in Tree A", groupLines(onlyA), opts.limit)
+	}
+	if len(onlyB) > 0 {
+		outputSection(opts.outFile, "Content only in Tree B", groupLines(onlyB), opts.limit)
+	}
+	if len(unreadable) > 0 {
+		outputSection(opts.outFile, "Unreadable (could not hash)", unreadable, opts.limit)
+	}
+	measureStorage(a, hashesA, b, hashesB).write(opts)
+	return len(moved) + len(onlyA) + len(onlyB) + len(unreadable)
+}
+
+// groupLines renders groups ordered by their first path, A before B.
+func groupLines(groups []*contentGroup) []string {
+	first := func(g *contentGroup) string {
+		if len(g.pathsA) > 0 {
+			return g.pathsA[0]
+		}
+		return g.pathsB[0]
+	}
+	sort.Slice(groups, func(i, j int) bool { return first(groups[i]) < first(groups[j]) })
+	lines := make([]string, len(groups))
+	for i, g := range groups {
+		lines[i] = g.line()
+	}
+	return lines
+}
+
+// line renders a group as "<short hash>  A: p, q  B: r".
+func (g *contentGroup) line() string {
+	list := func(paths []string) string {
+		if len(paths) == 0 {
+			return "(none)"
+		}
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s  A: %s  B: %s", g.hash[:12], list(g.pathsA), list(g.pathsB))
+}
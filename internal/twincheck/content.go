@@ -0,0 +1,90 @@
+package twincheck
+
+import "sort"
+
+// compareContent reports files present at the same relative path in both
+// trees whose content differs. Presence differences are ignored. A size
+// mismatch settles it without reading; same-size pairs are hashed, except
+// files the --hash-ext policy excludes, which are judged by size alone.
+// With --quick-compare same-size pairs are read side by side instead of
+// hashed. Failures in reportedA and reportedB were already recorded by the
+// structure pass of a --compare both run and are not recorded again.
+func compareContent(a, b *tree, opts options, reportedA, reportedB map[string]error) result {
+	var shared, toHash []string
+	for p, sizeA := range a.files {
+		sizeB, ok := b.files[p]
+		if !ok || !opts.filter.match(p) {
+			continue
+		}
+		shared = append(shared, p)
+		if sizeA == sizeB && opts.hashExt.hashable(p) {
+			toHash = append(toHash, p)
+		}
+	}
+	sort.Strings(shared)
+
+	// differ holds the offset of the first differing byte where it is
+	// known (--quick-compare), else -1
+	var differ map[string]int64
+	var errsA, errsB map[string]error
+	var hashesA, hashesB map[string]string
+	if opts.quickCompare {
+		differ, errsA, errsB = quickCompare(a, b, toHash, opts)
+	} else {
+		hashesA, errsA, hashesB, errsB = hashPair(a, toHash, b, toHash, opts)
+		differ = make(map[string]int64)
+		for _, p := range toHash {
+			hA, okA := hashesA[p]
+			hB, okB := hashesB[p]
+			if okA && okB && hA != hB {
+				differ[p] = -1
+			}
+		}
+	}
+
+	rec := newRecorder(a, b, opts)
+	for _, p := range shared {
+		if a.files[p] != b.files[p] {
+			rec.changed(p, -1)
+		} else if offset, ok := differ[p]; ok {
+			rec.changed(p, offset)
+		}
+	}
+	for p := range reportedA {
+		delete(errsA, p)
+	}
+	for p := range reportedB {
+		delete(errsB, p)
+	}
+	rec.unreadable(errsA, errsB)
+	res := rec.finish()
+	res.hashesA, res.hashesB = hashesA, hashesB
+	res.differs = make(map[string]bool, len(differ))
+	for p := range differ {
+		res.differs[p] = true
+	}
+	return res
+}
+
+// mergeContent adds the content differences of a --compare both run to the
+// structure result, near matches included.
+func mergeContent(res, content result) result {
+	res.changed = content.changed
+	res.hashesA = mergeHashes(res.hashesA, content.hashesA)
+	res.hashesB = mergeHashes(res.hashesB, content.hashesB)
+	res.differs = content.differs
+	res.errsA = mergeErrs(res.errsA, content.errsA)
+	res.errsB = mergeErrs(res.errsB, content.errsB)
+	res.near = append(res.near, content.near...)
+	sort.Strings(res.near)
+	res.unreadable = append(res.unreadable, content.unreadable...)
+	sort.Strings(res.unreadable)
+	res.diffs += content.diffs
+	for status, n := range content.byStatus {
+		if res.byStatus == nil {
+			res.byStatus = make(map[string]int)
+		}
+		res.byStatus[status] += n
+	}
+	return res
+}
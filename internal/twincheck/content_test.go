@@ -0,0 +1,68 @@
+package twincheck
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// failingFS is a local tree whose listed files cannot be opened.
+type failingFS struct {
+	localFS
+	fail map[string]bool
+}
+
+func (f failingFS) Open(rel string) (io.ReadCloser, error) {
+	if f.fail[rel] {
+		return nil, errors.New("simulated read error")
+	}
+	return f.localFS.Open(rel)
+}
+
+func TestCompareBothReportsUnreadableOnce(t *testing.T) {
+	dir := t.TempDir()
+	x, y := filepath.Join(dir, "x"), filepath.Join(dir, "y")
+	writeRandomFile(t, x, "ok.txt", 100)
+	writeRandomFile(t, x, "locked.txt", 200)
+	writeRandomFile(t, y, "ok.txt", 100)
+	writeRandomFile(t, y, "locked.txt", 200)
+
+	for _, jsonl := range []bool{false, true} {
+		var emitted []diffRecord
+		opts := options{mode: "all", compare: "both"}
+		if jsonl {
+			opts.emit = func(r diffRecord) { emitted = append(emitted, r) }
+		}
+		a, _, _, err := loadTree(failingFS{localFS{x}, map[string]bool{"locked.txt": true}}, x, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _, _, err := loadTree(localFS{y}, y, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := compare(a, b, "strict", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Strict mode also lists B's copy as only in B, since A's hash is unknown
+		if res.byStatus["unreadable"] != 1 || res.diffs != 2 {
+			t.Errorf("jsonl=%v: diffs = %d, unreadable = %d; want 2 and 1", jsonl, res.diffs, res.byStatus["unreadable"])
+		}
+		if jsonl {
+			n := 0
+			for _, r := range emitted {
+				if r.Status == "unreadable" {
+					n++
+				}
+			}
+			if n != 1 {
+				t.Errorf("emitted %d unreadable records, want 1: %+v", n, emitted)
+			}
+		}
+		if !jsonl && len(res.unreadable) != 1 {
+			t.Errorf("listed %d unreadable files, want 1: %v", len(res.unreadable), res.unreadable)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package twincheck
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirDigests computes a Merkle-style digest for every directory in files.
+// A directory's digest covers the names and hashes of its files and the
+// names and digests of its subdirectories, so equal digests mean the whole
+// subtree is identical. Files without a hash make their ancestors unique.
+func dirDigests(files FileMap, hashes map[string]string) map[string]string {
+	children := make(map[string][]string) // dir -> "name\x00hash" entries
+	subdirs := make(map[string][]string)  // dir -> child dir paths
+	dirs := map[string]bool{".": true}
+
+	for path := range files {
+		h, ok := hashes[path]
+		if !ok {
+			h = "unreadable:" + path
+		}
+		dir := filepath.Dir(path)
+		children[dir] = append(children[dir], filepath.Base(path)+"\x00"+h)
+		for dir != "." && !dirs[dir] {
+			dirs[dir] = true
+			parent := filepath.Dir(dir)
+			subdirs[parent] = append(subdirs[parent], dir)
+			dir = parent
+		}
+	}
+
+	// Process deepest directories first so children are ready for parents
+	ordered := make([]string, 0, len(dirs))
+	for d := range dirs {
+		ordered = append(ordered, d)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return depthOf(ordered[i]) > depthOf(ordered[j])
+	})
+
+	digests := make(map[string]string, len(dirs))
+	for _, d := range ordered {
+		entries := append([]string(nil), children[d]...)
+		for _, sub := range subdirs[d] {
+			entries = append(entries, filepath.Base(sub)+"/\x00"+digests[sub])
+		}
+		sort.Strings(entries)
+		h := sha256.New()
+		for _, e := range entries {
+			fmt.Fprintln(h, e)
+		}
+		digests[d] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return digests
+}
+
+func depthOf(rel string) int {
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// compareDirDigests hashes every file in both trees and reports which
+// subtrees are identical and which have drifted, limited to directories
+// under --filter-prefix. It reports whether the trees differ.
+func compareDirDigests(a, b *tree, opts options) (bool, error) {
+	hashesA, _, hashesB, _ := hashPair(a, a.allPaths(), b, b.allPaths(), opts)
+	digestsA := dirDigests(a.files, hashesA)
+	digestsB := dirDigests(b.files, hashesB)
+
+	if digestsA["."] == digestsB["."] {
+		output(opts.outFile, "\nTrees are identical.")
+		return false, nil
+	}
+
+	var identical, differ, onlyA, onlyB []string
+	for dir, da := range digestsA {
+		if dir == "." || !opts.filter.match(dir) {
+			continue
+		}
+		db, ok := digestsB[dir]
+		switch {
+		case !ok:
+			onlyA = append(onlyA, dir)
+		case da == db:
+			// Only report the topmost identical directory of a subtree
+			parent := filepath.Dir(dir)
+			if parent == "." || digestsA[parent] != digestsB[parent] {
+				identical = append(identical, dir)
+			}
+		default:
+			differ = append(differ, dir)
+		}
+	}
+	for dir := range digestsB {
+		if !opts.filter.match(dir) {
+			continue
+		}
+		if _, ok := digestsA[dir]; !ok {
+			onlyB = append(onlyB, dir)
+		}
+	}
+
+	sort.Strings(identical)
+	sort.Strings(differ)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	outputSection(opts.outFile, "Identical subtrees", identical, opts.limit)
+	outputSection(opts.outFile, "Differing subtrees", differ, opts.limit)
+	if len(onlyA) > 0 {
+		outputSection(opts.outFile, "Directories only in Tree A", onlyA, opts.limit)
+	}
+	if len(onlyB) > 0 {
+		outputSection(opts.outFile, "Directories only in Tree B", onlyB, opts.limit)
+	}
+	return true, nil
+}
@@ -0,0 +1,60 @@
+package twincheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// failCategories maps each --fail-on category to the diffRecord status it
+// counts. missing_b and missing_a follow --mode: files absent from that tree.
+var failCategories = map[string]string{
+	"missing_b":  "only_a",
+	"missing_a":  "only_b",
+	"moved":      "moved",
+	"changed":    "changed",
+	"unreadable": "unreadable",
+}
+
+// failPolicy decides which differences make --fail-on-diff exit non-zero.
+// A nil statuses set means any difference does.
+type failPolicy struct {
+	statuses map[string]bool
+}
+
+// parseFailOn validates the --fail-on categories. "any" overrides the rest.
+func parseFailOn(categories []string) (failPolicy, error) {
+	var p failPolicy
+	for _, c := range categories {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "any" {
+			return failPolicy{}, nil
+		}
+		status, ok := failCategories[c]
+		if !ok {
+			return failPolicy{}, fmt.Errorf("invalid --fail-on: %s (use: any, missing_b, missing_a, moved, changed, unreadable)", c)
+		}
+		if p.statuses == nil {
+			p.statuses = make(map[string]bool)
+		}
+		p.statuses[status] = true
+	}
+	return p, nil
+}
+
+// any reports whether every kind of difference counts.
+func (p failPolicy) any() bool {
+	return p.statuses == nil
+}
+
+// trips reports whether res holds a difference the policy fails on.
+func (p failPolicy) trips(res result) bool {
+	if p.any() {
+		return res.diffs > 0
+	}
+	for status, n := range res.byStatus {
+		if p.statuses[status] && n > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter limits the reported differences to one subtree, given as a
+// relative path. The zero value matches everything.
+type pathFilter struct {
+	prefix string // cleaned, OS separators; "" = no filter
+}
+
+// newPathFilter parses --filter-prefix. Both / and the OS separator are
+// accepted; the prefix must stay inside the compared trees.
+func newPathFilter(prefix string) (pathFilter, error) {
+	if prefix == "" {
+		return pathFilter{}, nil
+	}
+	p := filepath.Clean(filepath.FromSlash(prefix))
+	if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return pathFilter{}, fmt.Errorf("--filter-prefix must be a path relative to the compared trees: %s", prefix)
+	}
+	if p == "." {
+		return pathFilter{}, nil
+	}
+	return pathFilter{prefix: p}, nil
+}
+
+func (f pathFilter) active() bool { return f.prefix != "" }
+
+// match reports whether rel is the prefix itself or lies beneath it. The
+// prefix is matched by whole path components, so "Photos" does not match
+// "Photos2/a.jpg".
+func (f pathFilter) match(rel string) bool {
+	if f.prefix == "" {
+		return true
+	}
+	return rel == f.prefix || strings.HasPrefix(rel, f.prefix+string(filepath.Separator))
+}
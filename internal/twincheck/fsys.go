@@ -0,0 +1,100 @@
+package twincheck
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// treeFS is the storage a live tree is read from. Paths passed to it are
+// relative to the tree root in filepath form, with "." for the root, so
+// the scanner and the hasher work the same way on every backend.
+type treeFS interface {
+	ReadDir(rel string) ([]fs.DirEntry, error)
+	Stat(rel string) (fs.FileInfo, error)
+	Open(rel string) (io.ReadCloser, error)
+	Close() error
+}
+
+// backends maps a URL scheme to the function that opens a tree on it.
+// Paths without a scheme are local directories.
+var backends = map[string]func(base string) (treeFS, error){
+	"sftp": openSFTP,
+}
+
+// openTreeFS returns the backend for base, chosen by its scheme.
+func openTreeFS(base string) (treeFS, error) {
+	scheme, _, ok := strings.Cut(base, "://")
+	if !ok {
+		return localFS{base: base}, nil
+	}
+	open, known := backends[strings.ToLower(scheme)]
+	if !known {
+		return nil, fmt.Errorf("unsupported scheme %s:// in %s (use a local path or sftp://)", scheme, base)
+	}
+	return open(base)
+}
+
+// cleanRoot gives a local root one canonical form: absolute, cleaned, with
+// the platform's separators and no trailing separator except on a volume
+// root. "-a /data/" and "-a /data", or "C:/data" and "C:\data" on
+// Windows, then scan and report identically. Remote roots are returned as
+// given.
+func cleanRoot(base string) (string, error) {
+	if base == "" || strings.Contains(base, "://") {
+		return base, nil
+	}
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", base, err)
+	}
+	return abs, nil
+}
+
+// redactRoot is base as it may be printed or saved: a URL with the
+// password in its userinfo masked, a local path unchanged. A URL that does
+// not parse has its whole userinfo masked.
+func redactRoot(base string) string {
+	scheme, rest, ok := strings.Cut(base, "://")
+	if !ok {
+		return base
+	}
+	if u, err := url.Parse(base); err == nil {
+		return u.Redacted()
+	}
+	authority, path, slash := strings.Cut(rest, "/")
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		authority = "xxxxx" + authority[at:]
+	}
+	if slash {
+		authority += "/" + path
+	}
+	return scheme + "://" + authority
+}
+
+// localFS reads a tree from a directory on the local filesystem.
+type localFS struct {
+	base string
+}
+
+func (l localFS) path(rel string) string {
+	return longPath(filepath.Join(l.base, rel))
+}
+
+func (l localFS) ReadDir(rel string) ([]fs.DirEntry, error) {
+	return os.ReadDir(l.path(rel))
+}
+
+func (l localFS) Stat(rel string) (fs.FileInfo, error) {
+	return os.Stat(l.path(rel))
+}
+
+func (l localFS) Open(rel string) (io.ReadCloser, error) {
+	return os.Open(l.path(rel))
+}
+
+func (localFS) Close() error { return nil }
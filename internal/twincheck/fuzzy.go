@@ -0,0 +1,103 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// fuzzyKey is the form --fuzzy-names compares relative paths in: each
+// component lowercased, stripped of accents, and with every run of
+// whitespace, punctuation and symbols collapsed to one space, so
+// "Café_Menu.pdf" and "cafe menu.pdf" agree.
+func fuzzyKey(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i, part := range parts {
+		var b strings.Builder
+		gap := false
+		for _, r := range norm.NFD.String(part) {
+			switch {
+			case unicode.Is(unicode.Mn, r):
+				continue // combining accent
+			case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+				gap = true
+				continue
+			}
+			if gap && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			gap = false
+			b.WriteRune(unicode.ToLower(r))
+		}
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, "/")
+}
+
+// pairFuzzy matches held-back one-sided files whose paths agree under
+// fuzzyKey and whose sizes are equal (or within --size-tolerance). Only
+// unambiguous pairs are taken: a key shared by two files on either side
+// pairs nothing, since guessing could hide a real missing file. What stays
+// unpaired is left pending.
+func (r *recorder) pairFuzzy() {
+	keysA := make(map[string][]string)
+	for _, p := range r.pendingA {
+		k := fuzzyKey(p)
+		keysA[k] = append(keysA[k], p)
+	}
+	keysB := make(map[string][]string)
+	for _, p := range r.pendingB {
+		k := fuzzyKey(p)
+		keysB[k] = append(keysB[k], p)
+	}
+
+	paired := make(map[string]bool)
+	for k, as := range keysA {
+		bs := keysB[k]
+		if len(as) != 1 || len(bs) != 1 {
+			continue
+		}
+		pa, pb := as[0], bs[0]
+		sizeA, sizeB := r.a.files[pa], r.b.files[pb]
+		gap := sizeB - sizeA
+		if gap < 0 {
+			gap = -gap
+		}
+		if !withinTolerance(gap, sizeA, sizeB, r.tolerance) {
+			continue
+		}
+		paired[pa], paired[pb] = true, true
+		r.fuzzyMatch(pa, pb)
+	}
+	r.pendingA = unpaired(r.pendingA, paired)
+	r.pendingB = unpaired(r.pendingB, paired)
+}
+
+// unpaired returns the paths not in paired, in their original order.
+func unpaired(paths []string, paired map[string]bool) []string {
+	var rest []string
+	for _, p := range paths {
+		if !paired[p] {
+			rest = append(rest, p)
+		}
+	}
+	return rest
+}
+
+// fuzzyMatch records a probable match found by --fuzzy-names. Like near
+// matches these count as matching, not as differences; either end under
+// --filter-prefix keeps the pair.
+func (r *recorder) fuzzyMatch(pathA, pathB string) {
+	if !r.filter.match(pathA) && !r.filter.match(pathB) {
+		return
+	}
+	sizeA, sizeB := r.a.files[pathA], r.b.files[pathB]
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "fuzzy_match", Path: pathA, To: pathB, Size: sizeA, SizeB: sizeB})
+		return
+	}
+	r.res.fuzzy = append(r.res.fuzzy, r.pairLine(pathA, pathB, fmt.Sprintf("%s ~ %s (probable match, name normalized)", pathA, pathB)))
+}
@@ -0,0 +1,62 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// rootUnit labels files that sit directly in the tree root when results
+// are aggregated.
+const rootUnit = "(top level)"
+
+// aggregate rolls one-sided paths up to --granularity units: dir groups
+// them by containing directory, top by first-level directory. Each unit
+// is listed once with the number of files under it; file keeps the paths.
+func aggregate(paths []string, granularity string) []string {
+	if granularity == "file" {
+		return paths
+	}
+	counts := make(map[string]int)
+	for _, p := range paths {
+		counts[unitOf(p, granularity)]++
+	}
+	units := make([]string, 0, len(counts))
+	for u := range counts {
+		units = append(units, u)
+	}
+	sort.Strings(units)
+	lines := make([]string, len(units))
+	for i, u := range units {
+		noun := "files"
+		if counts[u] == 1 {
+			noun = "file"
+		}
+		lines[i] = fmt.Sprintf("%s (%d %s)", u, counts[u], noun)
+	}
+	return lines
+}
+
+// unitOf is the directory p is counted under, with a trailing separator.
+func unitOf(p, granularity string) string {
+	dir := filepath.Dir(p)
+	if dir == "." {
+		return rootUnit
+	}
+	if granularity == "top" {
+		dir, _, _ = strings.Cut(dir, string(filepath.Separator))
+	}
+	return dir + string(filepath.Separator)
+}
+
+// granularityTitle is the suffix added to aggregated section titles.
+func granularityTitle(granularity string) string {
+	switch granularity {
+	case "dir":
+		return " by directory"
+	case "top":
+		return " by top-level directory"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package twincheck
+
+import (
+	"sync"
+	"time"
+)
+
+// hashCache remembers file hashes keyed by tree and path, invalidated when
+// the file's size or modification time changes. A nil cache hashes directly.
+type hashCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	fsys treeFS
+	rel  string
+}
+
+type cacheEntry struct {
+	size  int64
+	mtime time.Time
+	hash  string
+}
+
+func newHashCache() *hashCache {
+	return &hashCache{entries: make(map[cacheKey]cacheEntry)}
+}
+
+// hashFile returns the content hash of rel in fsys, reusing a cached value
+// when the file is unchanged. A cached file still counts toward progress.
+func (c *hashCache) hashFile(fsys treeFS, rel string, buf []byte, progress *hashProgress) (string, error) {
+	if c == nil {
+		return hashFile(fsys, rel, buf, progress)
+	}
+	info, err := fsys.Stat(rel)
+	if err != nil {
+		return "", err
+	}
+
+	key := cacheKey{fsys, rel}
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && e.size == info.Size() && e.mtime.Equal(info.ModTime()) {
+		progress.add(e.size)
+		return e.hash, nil
+	}
+
+	h, err := hashFile(fsys, rel, buf, progress)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{size: info.Size(), mtime: info.ModTime(), hash: h}
+	c.mu.Unlock()
+	return h, nil
+}
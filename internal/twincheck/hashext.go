@@ -0,0 +1,66 @@
+package twincheck
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// extPolicy decides by extension whether smart mode may hash a file. With
+// an allow list only those extensions are hashed; a deny list always wins.
+// Files that may not be hashed are judged by path and size alone.
+type extPolicy struct {
+	only  map[string]bool
+	never map[string]bool
+}
+
+func newExtPolicy(only, never []string) (extPolicy, error) {
+	var p extPolicy
+	for _, e := range only {
+		ext, err := normalizeExt("--hash-ext", e)
+		if err != nil {
+			return p, err
+		}
+		if p.only == nil {
+			p.only = make(map[string]bool)
+		}
+		p.only[ext] = true
+	}
+	for _, e := range never {
+		ext, err := normalizeExt("--no-hash-ext", e)
+		if err != nil {
+			return p, err
+		}
+		if p.never == nil {
+			p.never = make(map[string]bool)
+		}
+		p.never[ext] = true
+	}
+	return p, nil
+}
+
+func (p extPolicy) active() bool {
+	return p.only != nil || p.never != nil
+}
+
+// hashable reports whether rel may be content-hashed.
+func (p extPolicy) hashable(rel string) bool {
+	ext := strings.ToLower(filepath.Ext(rel))
+	if p.never[ext] {
+		return false
+	}
+	return p.only == nil || p.only[ext]
+}
+
+// hashablePaths filters paths down to those the policy allows hashing.
+func (p extPolicy) hashablePaths(paths []string) []string {
+	if !p.active() {
+		return paths
+	}
+	var out []string
+	for _, rel := range paths {
+		if p.hashable(rel) {
+			out = append(out, rel)
+		}
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ignoreRule excludes matching files from a tree by relative path and size.
+type ignoreRule struct {
+	label string
+	match func(rel string, size int64) bool
+}
+
+type ignoreRules []ignoreRule
+
+// newIgnoreRules builds rules from --ignore globs, matched against the base
+// name or the slash-separated relative path, and --exclude-ext extensions,
+// matched case-insensitively with or without the leading dot.
+func newIgnoreRules(globs, exts []string) (ignoreRules, error) {
+	var rules ignoreRules
+	for _, g := range globs {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid --ignore pattern %q: %w", g, err)
+		}
+		pattern := g
+		rules = append(rules, ignoreRule{
+			label: "--ignore " + g,
+			match: func(rel string, _ int64) bool {
+				return matchGlob(pattern, rel)
+			},
+		})
+	}
+	for _, e := range exts {
+		ext, err := normalizeExt("--exclude-ext", e)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ignoreRule{
+			label: "--exclude-ext " + ext,
+			match: func(rel string, _ int64) bool {
+				return strings.ToLower(filepath.Ext(rel)) == ext
+			},
+		})
+	}
+	return rules, nil
+}
+
+// skipRule is a --skip rule: files matching a glob and smaller than limit.
+type skipRule struct {
+	label   string
+	pattern string
+	limit   int64
+}
+
+type skipRules []skipRule
+
+// newSkipRules builds rules from --skip values of the form glob<size.
+func newSkipRules(skips []string) (skipRules, error) {
+	var rules skipRules
+	for _, sk := range skips {
+		pattern, limit, err := parseSkipRule(sk)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, skipRule{label: "--skip " + sk, pattern: pattern, limit: limit})
+	}
+	return rules, nil
+}
+
+func (s skipRule) match(rel string, size int64) bool {
+	return size < s.limit && matchGlob(s.pattern, strings.ToLower(rel))
+}
+
+// apply removes the paths a rule matches in every tree that holds them, and
+// returns how many each rule removed. Unlike the ignore rules it needs both
+// trees at once: a file below the limit on one side only is kept on both,
+// so the size change is still compared. filesB is nil for a single tree.
+func (r skipRules) apply(filesA FileMap, linksA linkMap, filesB FileMap, linksB linkMap) []int {
+	if len(r) == 0 {
+		return nil
+	}
+	counts := make([]int, len(r))
+	check := func(rel string) {
+		sizeA, inA := filesA[rel]
+		sizeB, inB := filesB[rel]
+		for i, rule := range r {
+			if (!inA || rule.match(rel, sizeA)) && (!inB || rule.match(rel, sizeB)) {
+				counts[i]++
+				delete(filesA, rel)
+				delete(linksA, rel)
+				delete(filesB, rel)
+				delete(linksB, rel)
+				return
+			}
+		}
+	}
+	for rel := range filesA {
+		check(rel)
+	}
+	for rel := range filesB {
+		check(rel)
+	}
+	return counts
+}
+
+// summary describes per-rule counts, or "" if there are no rules.
+func (r skipRules) summary(counts []int) string {
+	if len(r) == 0 {
+		return ""
+	}
+	total := 0
+	var parts []string
+	for i, n := range counts {
+		total += n
+		parts = append(parts, fmt.Sprintf("%s: %d", r[i].label, n))
+	}
+	return fmt.Sprintf("Skipped %d small files (%s)", total, strings.Join(parts, ", "))
+}
+
+// matchGlob matches pattern against the base name or the slash-separated
+// relative path.
+func matchGlob(pattern, rel string) bool {
+	if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.ToSlash(rel))
+	return ok
+}
+
+// parseSkipRule splits a --skip rule such as "*.jpg<100K" into its glob,
+// matched case-insensitively against lowered names, and its size limit.
+// Sizes take an optional K, M or G suffix (binary, with or without B).
+func parseSkipRule(rule string) (string, int64, error) {
+	i := strings.LastIndex(rule, "<")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid --skip %q: use glob<size, e.g. \"*.jpg<100K\"", rule)
+	}
+	pattern := strings.ToLower(strings.TrimSpace(rule[:i]))
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return "", 0, fmt.Errorf("invalid --skip pattern %q: %w", rule, err)
+	}
+
+	num := strings.ToUpper(strings.TrimSpace(rule[i+1:]))
+	num = strings.TrimSuffix(num, "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(num, "K"):
+		mult, num = 1<<10, strings.TrimSuffix(num, "K")
+	case strings.HasSuffix(num, "M"):
+		mult, num = 1<<20, strings.TrimSuffix(num, "M")
+	case strings.HasSuffix(num, "G"):
+		mult, num = 1<<30, strings.TrimSuffix(num, "G")
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return "", 0, fmt.Errorf("invalid --skip size in %q: use a positive number with an optional K, M or G suffix", rule)
+	}
+	return pattern, int64(n * float64(mult)), nil
+}
+
+// normalizeExt lowercases an extension and adds the leading dot.
+func normalizeExt(flag, e string) (string, error) {
+	ext := strings.ToLower(strings.TrimSpace(e))
+	if ext == "" || ext == "." {
+		return "", fmt.Errorf("invalid %s %q", flag, e)
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext, nil
+}
+
+// apply removes matching files from files and links and returns how many
+// each rule removed. A file is credited to the first rule that matches.
+func (r ignoreRules) apply(files FileMap, links linkMap) []int {
+	if len(r) == 0 {
+		return nil
+	}
+	counts := make([]int, len(r))
+	for rel, size := range files {
+		for i, rule := range r {
+			if rule.match(rel, size) {
+				counts[i]++
+				delete(files, rel)
+				delete(links, rel)
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// summary describes per-rule counts, or "" if nothing was excluded.
+func (r ignoreRules) summary(counts []int) string {
+	total := 0
+	var parts []string
+	for i, n := range counts {
+		total += n
+		parts = append(parts, fmt.Sprintf("%s: %d", r[i].label, n))
+	}
+	if total == 0 && len(r) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Excluded %d files (%s)", total, strings.Join(parts, ", "))
+}
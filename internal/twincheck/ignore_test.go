@@ -0,0 +1,25 @@
+package twincheck
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSkipRulesNeedBothSidesSmall(t *testing.T) {
+	rules, err := newSkipRules([]string{"*.jpg<100K"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := FileMap{"thumb.jpg": 10 << 10, "grew.jpg": 10 << 10, "onlyA.jpg": 10 << 10, "big.jpg": 1 << 20}
+	b := FileMap{"thumb.jpg": 12 << 10, "grew.jpg": 2 << 20, "onlyB.JPG": 10 << 10, "big.jpg": 1 << 20}
+
+	counts := rules.apply(a, nil, b, nil)
+	if !reflect.DeepEqual(counts, []int{3}) {
+		t.Errorf("counts = %v, want [3]", counts)
+	}
+	wantA := FileMap{"grew.jpg": 10 << 10, "big.jpg": 1 << 20}
+	wantB := FileMap{"grew.jpg": 2 << 20, "big.jpg": 1 << 20}
+	if !reflect.DeepEqual(a, wantA) || !reflect.DeepEqual(b, wantB) {
+		t.Errorf("after skipping: A = %v, B = %v; want %v and %v", a, b, wantA, wantB)
+	}
+}
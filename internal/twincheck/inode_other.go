@@ -0,0 +1,10 @@
+//go:build !unix
+
+package twincheck
+
+import "os"
+
+// inodeOf is not supported here; every path is treated as a distinct file.
+func inodeOf(info os.FileInfo) (inode, bool) {
+	return inode{}, false
+}
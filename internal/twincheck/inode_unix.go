@@ -0,0 +1,18 @@
+//go:build unix
+
+package twincheck
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the device+inode identity of a file with more than one
+// hardlink. Files with a single link are reported as not linked.
+func inodeOf(info os.FileInfo) (inode, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return inode{}, false
+	}
+	return inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
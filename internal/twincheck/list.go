@@ -0,0 +1,136 @@
+package twincheck
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadList reads a tree from a text file of paths, one per line, as
+// exported by another tool: "path" or "path<TAB>size". Blank lines are
+// skipped. Paths are relative to root; absolute paths are accepted when
+// they lie under root. A line without a size is sized from disk under
+// root. root may be empty when every line carries a size and nothing is
+// hashed; otherwise the listed files are read from it as from a scanned
+// tree.
+func loadList(path, root string, opts options) (*tree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &tree{base: path, files: make(FileMap), links: make(linkMap), bufSz: opts.readBuffer}
+	if root != "" {
+		if t.fsys, err = openTreeFS(root); err != nil {
+			return nil, fmt.Errorf("opening %s: %w", redactRoot(root), err)
+		}
+		t.base = redactRoot(root)
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, sizeField, hasSize := strings.Cut(line, "\t")
+		rel, err := listedPath(name, root)
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		var size int64
+		switch {
+		case hasSize:
+			if size, err = strconv.ParseInt(strings.TrimSpace(sizeField), 10, 64); err != nil || size < 0 {
+				t.close()
+				return nil, fmt.Errorf("%s:%d: invalid size %q", path, n, sizeField)
+			}
+		case t.fsys != nil:
+			info, err := t.fsys.Stat(rel)
+			if err != nil {
+				t.close()
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			size = info.Size()
+		default:
+			t.close()
+			return nil, fmt.Errorf("%s:%d: no size given; add path<TAB>size or the tree's root (-a/-b) to read it from disk", path, n)
+		}
+		t.files[rel] = size
+	}
+	if err := sc.Err(); err != nil {
+		t.close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	collisions := 0
+	if opts.normalize {
+		t.disk, collisions = normalizeNames(t.files, t.links)
+	}
+	output(opts.outFile, fmt.Sprintf("Loaded list %s: %d files", path, len(t.files)))
+	if collisions > 0 {
+		output(opts.outFile, fmt.Sprintf("  %d names normalize to a path already present and were kept as is", collisions))
+	}
+	if s := opts.ignore.summary(opts.ignore.apply(t.files, t.links)); s != "" {
+		output(opts.outFile, "  "+s)
+	}
+	if err := checkMinFiles(t, opts); err != nil {
+		t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// listedPath turns a listed path into the tree-relative form scanning
+// produces.
+func listedPath(name, root string) (string, error) {
+	p := filepath.FromSlash(name)
+	if filepath.IsAbs(p) {
+		if root == "" {
+			return "", fmt.Errorf("absolute path %s needs the tree's root (-a/-b) to be made relative", name)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s is not under %s", name, root)
+		}
+		p = rel
+	}
+	p = filepath.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q", name)
+	}
+	return p, nil
+}
+
+// loadListPair loads the two trees when at least one comes from a list.
+// The other side is a manifest or a scanned tree, as without lists.
+func loadListPair(driveA, listA, compareManifest, driveB, listB string, needHashes bool, opts options) (*tree, *tree, error) {
+	var a, b *tree
+	var err error
+	switch {
+	case listA != "":
+		a, err = loadList(listA, driveA, opts)
+	case compareManifest != "":
+		a, err = loadManifest(compareManifest, needHashes, opts)
+	default:
+		a, err = scanTree(driveA, opts)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if listB != "" {
+		b, err = loadList(listB, driveB, opts)
+	} else {
+		b, err = scanTree(driveB, opts)
+	}
+	if err != nil {
+		a.close()
+		return nil, nil, err
+	}
+	return a, b, nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package twincheck
+
+// longPath is a no-op outside Windows, where there is no MAX_PATH limit.
+func longPath(p string) string {
+	return p
+}
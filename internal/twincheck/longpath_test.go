@@ -0,0 +1,65 @@
+package twincheck
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// deepPath returns a relative path of nested directories longer than
+// Windows' 260-character MAX_PATH, ending in name.
+func deepPath(name string) string {
+	var parts []string
+	for i := 0; i < 12; i++ {
+		parts = append(parts, strings.Repeat(string(rune('a'+i)), 30))
+	}
+	return filepath.Join(append(parts, name)...)
+}
+
+func TestLongPathScanAndHash(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("MAX_PATH only limits Windows")
+	}
+	dir := t.TempDir()
+	rel := deepPath("deep.txt")
+	if len(filepath.Join(dir, rel)) <= 260 {
+		t.Fatalf("test path is only %d characters", len(filepath.Join(dir, rel)))
+	}
+	// The test itself needs the \\?\ form to create the tree
+	writeRandomFile(t, longPath(dir), rel, 4096)
+
+	fsys := localFS{base: dir}
+	files, _, denied, err := getFilesConcurrent(fsys, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denied) > 0 {
+		t.Fatalf("denied directories: %v", denied)
+	}
+	if size, ok := files[rel]; !ok || size != 4096 {
+		t.Fatalf("files[%q] = %d, %v; want 4096, true (found %v)", rel, size, ok, files)
+	}
+	if _, err := hashFile(fsys, rel, make([]byte, defaultReadBuffer), nil); err != nil {
+		t.Fatalf("hashing %s: %v", rel, err)
+	}
+}
+
+func TestScanPathsHaveNoLongPathPrefix(t *testing.T) {
+	dir := t.TempDir()
+	writeRandomFile(t, dir, filepath.Join("sub", "file.txt"), 10)
+	writeRandomFile(t, dir, "top.txt", 10)
+
+	files, _, _, err := getFilesConcurrent(localFS{base: dir}, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("found %d files, want 2: %v", len(files), files)
+	}
+	for rel := range files {
+		if strings.HasPrefix(rel, `\\?\`) || filepath.IsAbs(rel) {
+			t.Errorf("display path %q is not a plain relative path", rel)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package twincheck
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath returns p in \\?\ form so Win32 calls accept paths longer than
+// MAX_PATH (260). It is applied only right before filesystem calls; the
+// paths stored and displayed stay in their ordinary form.
+func longPath(p string) string {
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:] // \\server\share\... -> \\?\UNC\server\share\...
+	}
+	return `\\?\` + abs
+}
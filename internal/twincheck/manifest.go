@@ -0,0 +1,83 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/manifest"
+)
+
+// saveManifest hashes every file in t and writes the snapshot to path in
+// the shared manifest format.
+func saveManifest(path string, t *tree) error {
+	paths := t.allPaths()
+	sort.Strings(paths)
+	hashes, _ := t.hash(paths)
+
+	m := manifest.Manifest{Version: manifest.Version, Root: t.base, Created: time.Now().UTC(), Algorithm: "sha256"}
+	for _, p := range paths {
+		e := manifest.Entry{Path: filepath.ToSlash(p), Size: t.files[p], Hash: hashes[p]}
+		if info, err := t.fsys.Stat(t.disk.onDisk(p)); err == nil {
+			e.ModTime = info.ModTime().UTC()
+		}
+		m.Files = append(m.Files, e)
+	}
+	if err := m.Save(path); err != nil {
+		return fmt.Errorf("writing manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadManifest reads a saved snapshot as a tree. Modes that hash need a
+// manifest with sha256 hashes, which is what twincheck itself computes.
+func loadManifest(path string, needHashes bool, opts options) (*tree, error) {
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if needHashes && m.Algorithm != "sha256" {
+		if !m.HasHashes() {
+			return nil, fmt.Errorf("manifest %s has no hashes; rebuild it with hashing or use --hash-mode off", path)
+		}
+		return nil, fmt.Errorf("manifest %s uses %s hashes; twincheck compares sha256", path, m.Algorithm)
+	}
+
+	t := &tree{
+		base:   m.Root,
+		files:  make(FileMap, len(m.Files)),
+		links:  make(linkMap),
+		hashes: make(map[string]string, len(m.Files)),
+	}
+	for _, e := range m.Files {
+		rel := filepath.FromSlash(e.Path)
+		t.files[rel] = e.Size
+		if e.Hash != "" {
+			t.hashes[rel] = e.Hash
+		}
+	}
+	collisions := 0
+	if opts.normalize {
+		var disk diskNames
+		disk, collisions = normalizeNames(t.files, t.links)
+		for n, p := range disk {
+			if h, ok := t.hashes[p]; ok {
+				t.hashes[n] = h
+				delete(t.hashes, p)
+			}
+		}
+	}
+	output(opts.outFile, fmt.Sprintf("Loaded manifest %s: %d files from %s (captured %s)",
+		path, len(t.files), m.Root, m.Created.Local().Format(time.RFC3339)))
+	if collisions > 0 {
+		output(opts.outFile, fmt.Sprintf("  %d names normalize to a path already present and were kept as is", collisions))
+	}
+	if s := opts.ignore.summary(opts.ignore.apply(t.files, t.links)); s != "" {
+		output(opts.outFile, "  "+s)
+	}
+	if err := checkMinFiles(t, opts); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,70 @@
+package twincheck
+
+import (
+	"sort"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// diskNames maps a normalized relative path back to the name actually on
+// disk, for the paths --normalize-unicode rewrote. A nil map is the identity.
+type diskNames map[string]string
+
+// onDisk returns the on-disk form of rel.
+func (d diskNames) onDisk(rel string) string {
+	if p, ok := d[rel]; ok {
+		return p
+	}
+	return rel
+}
+
+// normalizeNames rewrites the keys of files and links to Unicode NFC, so a
+// name stored decomposed (NFD, as macOS does) matches the same name stored
+// composed elsewhere. When two distinct names on disk normalize to the same
+// path, the first in sort order keeps it and the other stays as is; their
+// number is returned.
+func normalizeNames(files FileMap, links linkMap) (diskNames, int) {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		if !norm.NFC.IsNormalString(p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, 0
+	}
+	sort.Strings(paths)
+
+	disk := make(diskNames, len(paths))
+	renamed := make(map[string]string, len(paths))
+	collisions := 0
+	for _, p := range paths {
+		n := norm.NFC.String(p)
+		if _, taken := files[n]; taken {
+			collisions++
+			continue
+		}
+		files[n] = files[p]
+		delete(files, p)
+		disk[n] = p
+		renamed[p] = n
+	}
+
+	if len(links) > 0 && len(renamed) > 0 {
+		rekeyed := make(linkMap, len(links))
+		for p, canon := range links {
+			if n, ok := renamed[p]; ok {
+				p = n
+			}
+			if n, ok := renamed[canon]; ok {
+				canon = n
+			}
+			rekeyed[p] = canon
+		}
+		clear(links)
+		for p, canon := range rekeyed {
+			links[p] = canon
+		}
+	}
+	return disk, collisions
+}
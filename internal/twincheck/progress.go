@@ -0,0 +1,107 @@
+package twincheck
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often hashing progress is redrawn.
+const progressInterval = 2 * time.Second
+
+// hashProgress counts bytes as they are hashed and redraws a percentage of
+// the total expected, which tracks the work left far better than a file
+// count when sizes vary widely. It is an io.Writer fed alongside the hash.
+// A nil *hashProgress counts nothing.
+type hashProgress struct {
+	done  atomic.Int64
+	total int64
+	start time.Time
+	out   io.Writer
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// startProgress begins redrawing progress on stderr, or returns nil when
+// stderr is not a terminal or there is nothing to hash.
+func startProgress(total int64) *hashProgress {
+	if total <= 0 || !isTerminal(os.Stderr) {
+		return nil
+	}
+	p := &hashProgress{total: total, start: time.Now(), out: os.Stderr, stop: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		tick := time.NewTicker(progressInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				p.draw()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *hashProgress) Write(b []byte) (int, error) {
+	p.add(int64(len(b)))
+	return len(b), nil
+}
+
+// add counts n bytes, e.g. for a file answered from the hash cache.
+func (p *hashProgress) add(n int64) {
+	if p != nil {
+		p.done.Add(n)
+	}
+}
+
+// draw prints the percentage, bytes and an estimate of the time left.
+func (p *hashProgress) draw() {
+	done := p.done.Load()
+	line := fmt.Sprintf("Hashing: %.1f%% (%s of %s)", float64(done)*100/float64(p.total), humanSize(done), humanSize(p.total))
+	if elapsed := time.Since(p.start); done > 0 && done < p.total {
+		left := time.Duration(float64(elapsed) * float64(p.total-done) / float64(done))
+		line += fmt.Sprintf(", about %v left", left.Round(time.Second))
+	}
+	fmt.Fprintf(p.out, "\r%-72s", line)
+}
+
+// finish stops redrawing and clears the progress line.
+func (p *hashProgress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	fmt.Fprintf(p.out, "\r%-72s\r", "")
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// hashBytes is how many bytes hashing paths in t would read: each
+// hardlinked inode once, and nothing for a manifest.
+func (t *tree) hashBytes(paths []string) int64 {
+	if t.hashes != nil {
+		return 0
+	}
+	seen := make(map[string]bool, len(paths))
+	var total int64
+	for _, p := range paths {
+		c := t.links.canonical(p)
+		if !seen[c] {
+			seen[c] = true
+			total += t.files[c]
+		}
+	}
+	return total
+}
@@ -0,0 +1,116 @@
+package twincheck
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// quickCompare reads each same-size pair in paths from both trees side by
+// side in --read-buffer blocks and stops at the first differing block, so
+// pairs that differ early cost only a partial read. Identical pairs are
+// read in full, as hashing would, but without computing digests. It returns
+// the paths whose content differs with the byte offset of their first
+// difference; files that could not be opened or read are returned per tree
+// in errsA and errsB instead, never as identical.
+func quickCompare(a, b *tree, paths []string, opts options) (differ map[string]int64, errsA, errsB map[string]error) {
+	differ = make(map[string]int64)
+	errsA = make(map[string]error)
+	errsB = make(map[string]error)
+	if len(paths) == 0 {
+		return
+	}
+	bufSize := opts.readBuffer
+	if bufSize <= 0 {
+		bufSize = defaultReadBuffer
+	}
+	// Each pair reads both drives, so the busier limit of the two applies
+	numWorkers := a.workers
+	if b.workers < numWorkers {
+		numWorkers = b.workers
+	}
+	if numWorkers <= 0 {
+		numWorkers = defaultHashWorkers
+	}
+	if len(paths) < numWorkers {
+		numWorkers = len(paths)
+	}
+
+	jobs := make(chan string, len(paths))
+	for _, p := range paths {
+		jobs <- p
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bufA, bufB := make([]byte, bufSize), make([]byte, bufSize)
+			for rel := range jobs {
+				offset, errA, errB := firstDifference(a, b, rel, bufA, bufB)
+				mu.Lock()
+				switch {
+				case errA != nil || errB != nil:
+					if errA != nil {
+						errsA[rel] = errA
+					}
+					if errB != nil {
+						errsB[rel] = errB
+					}
+				case offset >= 0:
+					differ[rel] = offset
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+// firstDifference compares rel in a and b block by block and returns the
+// offset of the first byte that differs, or -1 if the contents are equal.
+// A file that ends early differs at its length.
+func firstDifference(a, b *tree, rel string, bufA, bufB []byte) (offset int64, errA, errB error) {
+	fa, errA := a.fsys.Open(a.disk.onDisk(rel))
+	fb, errB := b.fsys.Open(b.disk.onDisk(rel))
+	if fa != nil {
+		defer fa.Close()
+	}
+	if fb != nil {
+		defer fb.Close()
+	}
+	if errA != nil || errB != nil {
+		return -1, errA, errB
+	}
+	var pos int64
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return -1, errA, nil
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return -1, nil, errB
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			n := min(na, nb)
+			i := 0
+			for i < n && bufA[i] == bufB[i] {
+				i++
+			}
+			return pos + int64(i), nil, nil
+		}
+		if errA != nil || errB != nil {
+			// Both ended together only if both hit EOF on this block
+			if errA != nil && errB != nil {
+				return -1, nil, nil
+			}
+			return pos + int64(na), nil, nil
+		}
+		pos += int64(na)
+	}
+}
@@ -0,0 +1,366 @@
+package twincheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// diffRecord is one line of --format jsonl output.
+type diffRecord struct {
+	Status string `json:"status"` // only_a | only_b | moved | near_match | fuzzy_match | changed | unreadable
+	Path   string `json:"path"`
+	To     string `json:"to,omitempty"` // moved, fuzzy_match, near_match across paths: the path in Tree B
+	Size   int64  `json:"size"`
+	SizeB  int64  `json:"size_b,omitempty"` // near_match, fuzzy_match, changed: the size in Tree B
+	Tree   string `json:"tree,omitempty"`   // unreadable: A or B
+	Offset *int64 `json:"offset,omitempty"` // changed, --quick-compare: first differing byte
+	Error  string `json:"error,omitempty"`
+}
+
+// jsonlEmitter writes each record as a JSON line to w as soon as it is known.
+func jsonlEmitter(w io.Writer) func(diffRecord) {
+	enc := json.NewEncoder(w)
+	return func(r diffRecord) { enc.Encode(r) }
+}
+
+// recorder receives differences as the comparison finds them. It collects
+// them into a result for the text report or, when emit is set, streams
+// each one and keeps only a count. Entries hidden by --mode or outside
+// --filter-prefix are dropped. With --size-tolerance or --fuzzy-names,
+// one-sided entries are held back until finish pairs them into near or
+// probable matches.
+type recorder struct {
+	a, b      *tree
+	mode      string
+	filter    pathFilter
+	emit      func(diffRecord)
+	tolerance float64 // percent; 0 = exact sizes only
+	widen     bool    // smart: pair leftover one-sided entries by near size
+	fuzzy     bool    // pair leftover one-sided entries by fuzzyKey
+	showPath  string  // a | b | both: which side two-sided entries print
+	pendingA  []string
+	pendingB  []string
+	res       result
+}
+
+func newRecorder(a, b *tree, opts options) *recorder {
+	return &recorder{a: a, b: b, mode: opts.mode, filter: opts.filter, emit: opts.emit, tolerance: opts.sizeTolerance, fuzzy: opts.fuzzyNames, showPath: opts.showPath}
+}
+
+// count tallies one difference under its diffRecord status.
+func (r *recorder) count(status string) {
+	r.res.diffs++
+	if r.res.byStatus == nil {
+		r.res.byStatus = make(map[string]int)
+	}
+	r.res.byStatus[status]++
+}
+
+// holding reports whether one-sided entries wait for finish.
+func (r *recorder) holding() bool {
+	return r.tolerance > 0 || r.fuzzy
+}
+
+func (r *recorder) onlyA(path string) {
+	if r.holding() {
+		r.pendingA = append(r.pendingA, path)
+		return
+	}
+	r.recordOnlyA(path)
+}
+
+func (r *recorder) onlyB(path string) {
+	if r.holding() {
+		r.pendingB = append(r.pendingB, path)
+		return
+	}
+	r.recordOnlyB(path)
+}
+
+func (r *recorder) recordOnlyA(path string) {
+	if r.mode == "missing_a" || !r.filter.match(path) {
+		return
+	}
+	r.count("only_a")
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "only_a", Path: path, Size: r.a.files[path]})
+		return
+	}
+	r.res.onlyA = append(r.res.onlyA, path)
+}
+
+func (r *recorder) recordOnlyB(path string) {
+	if r.mode == "missing_b" || !r.filter.match(path) {
+		return
+	}
+	r.count("only_b")
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "only_b", Path: path, Size: r.b.files[path]})
+		return
+	}
+	r.res.onlyB = append(r.res.onlyB, path)
+}
+
+// moved keeps a pair when either end lies under --filter-prefix.
+func (r *recorder) moved(from, to string) {
+	if !r.filter.match(from) && !r.filter.match(to) {
+		return
+	}
+	r.count("moved")
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "moved", Path: from, To: to, Size: r.a.files[from]})
+		return
+	}
+	r.res.moved = append(r.res.moved, r.pairLine(from, to, from+" -> "+to))
+}
+
+// pairLine is how a two-sided text entry is printed: with --show-path a or
+// b just that side's path, so the list can feed a copy or sync tool,
+// otherwise the full form naming both sides.
+func (r *recorder) pairLine(pathA, pathB, both string) string {
+	switch r.showPath {
+	case "a":
+		return pathA
+	case "b":
+		return pathB
+	}
+	return both
+}
+
+// changed records a file present at the same path in both trees whose
+// content differs. offset is the first differing byte, or -1 if unknown.
+// Size differences within --size-tolerance are near matches instead.
+func (r *recorder) changed(path string, offset int64) {
+	if !r.filter.match(path) {
+		return
+	}
+	if r.nearSize(path) {
+		r.nearMatch(path)
+		return
+	}
+	sizeA, sizeB := r.a.files[path], r.b.files[path]
+	r.count("changed")
+	if r.emit != nil {
+		rec := diffRecord{Status: "changed", Path: path, Size: sizeA, SizeB: sizeB}
+		if offset >= 0 {
+			rec.Offset = &offset
+		}
+		r.emit(rec)
+		return
+	}
+	line := path
+	if sizeA != sizeB {
+		line = fmt.Sprintf("%s (%d vs %d bytes)", path, sizeA, sizeB)
+	} else if offset >= 0 {
+		line = fmt.Sprintf("%s (first difference at byte %d)", path, offset)
+	}
+	r.res.changed = append(r.res.changed, r.pairLine(path, path, line))
+}
+
+// unreadable records hashing failures for both trees, ordered by path.
+func (r *recorder) unreadable(errsA, errsB map[string]error) {
+	r.res.errsA = mergeErrs(r.res.errsA, errsA)
+	r.res.errsB = mergeErrs(r.res.errsB, errsB)
+	for _, side := range []struct {
+		name  string
+		t     *tree
+		errs  map[string]error
+		items []string
+	}{{"A", r.a, errsA, nil}, {"B", r.b, errsB, nil}} {
+		paths := make([]string, 0, len(side.errs))
+		for p := range side.errs {
+			if r.filter.match(p) {
+				paths = append(paths, p)
+			}
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			r.count("unreadable")
+			if r.emit != nil {
+				r.emit(diffRecord{Status: "unreadable", Path: p, Size: side.t.files[p], Tree: side.name, Error: side.errs[p].Error()})
+				continue
+			}
+			r.res.unreadable = append(r.res.unreadable, fmt.Sprintf("[%s] %s: %v", side.name, p, side.errs[p]))
+		}
+	}
+}
+
+// nearMatch records a path present in both trees whose sizes differ but
+// are within --size-tolerance. Near matches count as matching, not as
+// differences.
+func (r *recorder) nearMatch(path string) {
+	if !r.filter.match(path) {
+		return
+	}
+	sizeA, sizeB := r.a.files[path], r.b.files[path]
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "near_match", Path: path, Size: sizeA, SizeB: sizeB})
+		return
+	}
+	r.res.near = append(r.res.near, fmt.Sprintf("%s (%d vs %d bytes)", path, sizeA, sizeB))
+}
+
+// nearMove records a one-sided file in A paired by near size with one in
+// B at another path; either end under --filter-prefix keeps the pair.
+func (r *recorder) nearMove(pathA, pathB string) {
+	if !r.filter.match(pathA) && !r.filter.match(pathB) {
+		return
+	}
+	sizeA, sizeB := r.a.files[pathA], r.b.files[pathB]
+	if r.emit != nil {
+		r.emit(diffRecord{Status: "near_match", Path: pathA, To: pathB, Size: sizeA, SizeB: sizeB})
+		return
+	}
+	r.res.near = append(r.res.near, r.pairLine(pathA, pathB, fmt.Sprintf("%s -> %s (%d vs %d bytes)", pathA, pathB, sizeA, sizeB)))
+}
+
+// samePathNear records the files at the same path in both trees whose
+// sizes differ within --size-tolerance. Off and smart mode match files by
+// path alone, so without this such drift would go unreported.
+func (r *recorder) samePathNear() {
+	var paths []string
+	for p, sizeA := range r.a.files {
+		if sizeB, ok := r.b.files[p]; ok && sizeA != sizeB && r.nearSize(p) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		r.nearMatch(p)
+	}
+}
+
+// nearSize reports whether path's sizes in A and B differ by no more than
+// --size-tolerance.
+func (r *recorder) nearSize(path string) bool {
+	sizeA, sizeB := r.a.files[path], r.b.files[path]
+	gap := sizeB - sizeA
+	if gap < 0 {
+		gap = -gap
+	}
+	return r.tolerance > 0 && withinTolerance(gap, sizeA, sizeB, r.tolerance)
+}
+
+// pairNear matches held-back one-sided files found at the identical
+// relative path in both trees, as --by-name (which keys on size) and
+// strict mode leave them, whose sizes are within the tolerance. A file is
+// never paired with one in another directory. What stays unpaired is left
+// pending.
+func (r *recorder) pairNear() {
+	inB := make(map[string]bool, len(r.pendingB))
+	for _, p := range r.pendingB {
+		inB[p] = true
+	}
+	paired := make(map[string]bool)
+	for _, p := range r.pendingA {
+		if inB[p] && r.nearSize(p) {
+			paired[p] = true
+			r.nearMatch(p)
+		}
+	}
+	r.pendingA = unpaired(r.pendingA, paired)
+	r.pendingB = unpaired(r.pendingB, paired)
+}
+
+// pairNearSize widens smart mode's size buckets for what is still one-sided:
+// a file in A is paired with a file in B whose size differs, but within
+// the tolerance, and whose extension is the same. Only unambiguous pairs
+// are taken: a file with two such candidates pairs nothing. Equal sizes
+// are skipped, as smart mode has already hashed those and found them to
+// differ.
+func (r *recorder) pairNearSize() {
+	sortedA := bySize(r.pendingA, r.a.files)
+	sortedB := bySize(r.pendingB, r.b.files)
+	paired := make(map[string]bool)
+	for _, pa := range r.pendingA {
+		pb, ok := r.nearSizeMatch(pa, r.a.files[pa], sortedB, r.b.files)
+		if !ok {
+			continue
+		}
+		if back, ok := r.nearSizeMatch(pb, r.b.files[pb], sortedA, r.a.files); !ok || back != pa {
+			continue
+		}
+		paired[pa], paired[pb] = true, true
+		r.nearMove(pa, pb)
+	}
+	r.pendingA = unpaired(r.pendingA, paired)
+	r.pendingB = unpaired(r.pendingB, paired)
+}
+
+// bySize returns paths ordered by their size in files.
+func bySize(paths []string, files FileMap) []string {
+	sorted := append([]string(nil), paths...)
+	sort.Slice(sorted, func(i, j int) bool { return files[sorted[i]] < files[sorted[j]] })
+	return sorted
+}
+
+// nearSizeMatch returns the only path in sorted, ordered by its size in
+// files, with path's extension and a different size within the tolerance
+// of size.
+func (r *recorder) nearSizeMatch(path string, size int64, sorted []string, files FileMap) (string, bool) {
+	lowest := int64(float64(size) * (1 - r.tolerance/100))
+	var match string
+	n := 0
+	for i := sort.Search(len(sorted), func(i int) bool { return files[sorted[i]] >= lowest }); i < len(sorted); i++ {
+		other := files[sorted[i]]
+		gap := other - size
+		if gap < 0 {
+			gap = -gap
+		}
+		if !withinTolerance(gap, size, other, r.tolerance) {
+			if other > size {
+				break // every later size is further off
+			}
+			continue
+		}
+		if gap > 0 && strings.EqualFold(filepath.Ext(path), filepath.Ext(sorted[i])) {
+			match = sorted[i]
+			n++
+		}
+	}
+	return match, n == 1
+}
+
+// withinTolerance reports whether two sizes differing by gap bytes are
+// within percent of the larger one.
+func withinTolerance(gap, sizeA, sizeB int64, percent float64) bool {
+	larger := sizeA
+	if sizeB > larger {
+		larger = sizeB
+	}
+	return float64(gap) <= float64(larger)*percent/100
+}
+
+// finish sorts the collected lists and returns the result.
+// Held-back entries still unpaired are recorded as only in A or B after all.
+func (r *recorder) finish() result {
+	if r.tolerance > 0 {
+		r.pairNear()
+		if r.widen {
+			r.pairNearSize()
+		}
+	}
+	if r.fuzzy {
+		r.pairFuzzy()
+	}
+	sort.Strings(r.pendingA)
+	sort.Strings(r.pendingB)
+	for _, p := range r.pendingA {
+		r.recordOnlyA(p)
+	}
+	for _, p := range r.pendingB {
+		r.recordOnlyB(p)
+	}
+	r.pendingA, r.pendingB = nil, nil
+	sort.Strings(r.res.near)
+	sort.Strings(r.res.fuzzy)
+	sort.Strings(r.res.changed)
+	sort.Strings(r.res.onlyA)
+	sort.Strings(r.res.onlyB)
+	sort.Strings(r.res.unreadable)
+	return r.res
+}
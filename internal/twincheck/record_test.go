@@ -0,0 +1,56 @@
+package twincheck
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSizeToleranceNearMatches(t *testing.T) {
+	dir := t.TempDir()
+	x, y := filepath.Join(dir, "x"), filepath.Join(dir, "y")
+	writeRandomFile(t, x, "drift.jpg", 1000)
+	writeRandomFile(t, y, "drift.jpg", 1010)
+	writeRandomFile(t, x, "far.jpg", 1000)
+	writeRandomFile(t, y, "far.jpg", 2000)
+	writeRandomFile(t, x, filepath.Join("old", "photo.jpg"), 5000)
+	writeRandomFile(t, y, filepath.Join("new", "photo-1.jpg"), 5100)
+	writeRandomFile(t, y, filepath.Join("new", "notes.txt"), 5050)
+
+	opts := options{mode: "all", compare: "structure", sizeTolerance: 5}
+	a, _, _, err := loadTree(localFS{x}, x, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, _, err := loadTree(localFS{y}, y, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drift := "drift.jpg (1000 vs 1010 bytes)"
+	moved := filepath.Join("old", "photo.jpg") + " -> " + filepath.Join("new", "photo-1.jpg") + " (5000 vs 5100 bytes)"
+	for _, tc := range []struct {
+		mode  string
+		near  []string
+		onlyA int
+		onlyB int
+	}{
+		{"off", []string{drift}, 1, 2},
+		// notes.txt is near in size too, but has another extension
+		{"smart", []string{drift, moved}, 0, 1},
+	} {
+		res, err := compareStructure(a, b, tc.mode, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res.near, tc.near) {
+			t.Errorf("%s: near = %q, want %q", tc.mode, res.near, tc.near)
+		}
+		if len(res.onlyA) != tc.onlyA || len(res.onlyB) != tc.onlyB {
+			t.Errorf("%s: %d only in A, %d only in B; want %d and %d", tc.mode, len(res.onlyA), len(res.onlyB), tc.onlyA, tc.onlyB)
+		}
+		if res.byStatus["near_match"] != 0 {
+			t.Errorf("%s: near matches were counted as differences", tc.mode)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package twincheck
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/bryanbarcelona/data-symmetry/internal/manifest"
+)
+
+// selfCheck compares a live tree against an earlier manifest of itself.
+// Files whose size or mtime changed are reported as modified; files whose
+// size and mtime are unchanged are re-hashed, and a different hash means
+// silent corruption. It returns the number of differences found.
+func selfCheck(base, manifestPath string, opts options) (int, error) {
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		return 0, err
+	}
+	if m.Algorithm != "sha256" {
+		return 0, fmt.Errorf("manifest %s has no sha256 hashes; save one with --save-manifest or 'ds scan --hash'", manifestPath)
+	}
+	output(opts.outFile, fmt.Sprintf("Checking %s against manifest %s (captured %s)", redactRoot(base), manifestPath, m.Created.Local().Format("2006-01-02 15:04")))
+
+	saved := make(FileMap, len(m.Files))
+	for _, e := range m.Files {
+		saved[filepath.FromSlash(e.Path)] = e.Size
+	}
+	opts.ignore.apply(saved, nil)
+
+	t, err := scanTree(base, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer t.close()
+	if s := opts.skip.summary(opts.skip.apply(saved, nil, t.files, t.links)); s != "" {
+		output(opts.outFile, "  "+s)
+	}
+	entries := make(map[string]manifest.Entry, len(saved))
+	for _, e := range m.Files {
+		if _, ok := saved[filepath.FromSlash(e.Path)]; ok {
+			entries[filepath.FromSlash(e.Path)] = e
+		}
+	}
+	t.workers = opts.hashWorkersA
+
+	var added, removed, modified, corrupted, unreadable []string
+	var unchanged []string
+	for rel := range t.files {
+		if !opts.filter.match(rel) {
+			continue
+		}
+		if _, ok := entries[rel]; !ok {
+			added = append(added, rel)
+		}
+	}
+	for rel, e := range entries {
+		if !opts.filter.match(rel) {
+			continue
+		}
+		size, ok := t.files[rel]
+		if !ok {
+			removed = append(removed, rel)
+			continue
+		}
+		info, err := t.fsys.Stat(rel)
+		if err != nil {
+			unreadable = append(unreadable, fmt.Sprintf("%s: %v", rel, err))
+			continue
+		}
+		if size != e.Size || !info.ModTime().Equal(e.ModTime) {
+			modified = append(modified, rel)
+			continue
+		}
+		if e.Hash == "" {
+			unreadable = append(unreadable, fmt.Sprintf("%s: %v", rel, errNotInManifest))
+			continue
+		}
+		unchanged = append(unchanged, rel)
+	}
+
+	output(opts.outFile, fmt.Sprintf("Re-hashing %d files with unchanged size and mtime...", len(unchanged)))
+	hashes, errs := t.hash(unchanged)
+	for _, rel := range unchanged {
+		if err, bad := errs[rel]; bad {
+			unreadable = append(unreadable, fmt.Sprintf("%s: %v", rel, err))
+		} else if hashes[rel] != entries[rel].Hash {
+			corrupted = append(corrupted, rel)
+		}
+	}
+
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Added since manifest", added},
+		{"Removed since manifest", removed},
+		{"Modified (size or mtime changed)", modified},
+		{"Corrupted (content changed, size and mtime unchanged)", corrupted},
+		{"Unreadable (could not hash)", unreadable},
+	}
+	diffs := 0
+	for _, sec := range sections {
+		sort.Strings(sec.items)
+		diffs += len(sec.items)
+		if len(sec.items) > 0 {
+			outputSection(opts.outFile, sec.title, sec.items, opts.limit)
+		}
+	}
+	if diffs == 0 {
+		output(opts.outFile, "\nTree matches the manifest.")
+	}
+	reportDenied(opts.outFile, t)
+	return diffs, nil
+}
@@ -0,0 +1,174 @@
+package twincheck
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpFS reads a tree from an SFTP server over SSH.
+type sftpFS struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	agent  net.Conn // ssh-agent connection the agent's keys sign through; nil without one
+	root   string   // remote directory, slash-separated
+}
+
+func (s *sftpFS) path(rel string) string {
+	return path.Join(s.root, filepath.ToSlash(rel))
+}
+
+func (s *sftpFS) ReadDir(rel string) ([]fs.DirEntry, error) {
+	infos, err := s.client.ReadDir(s.path(rel))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+func (s *sftpFS) Stat(rel string) (fs.FileInfo, error) {
+	return s.client.Stat(s.path(rel))
+}
+
+func (s *sftpFS) Open(rel string) (io.ReadCloser, error) {
+	return s.client.Open(s.path(rel))
+}
+
+func (s *sftpFS) Close() error {
+	s.client.Close()
+	err := s.conn.Close()
+	closeAgent(s.agent)
+	return err
+}
+
+// openSFTP connects to sftp://[user@]host[:port]/path. A path under /~/ is
+// taken relative to the login directory. The server must already be in
+// ~/.ssh/known_hosts; authentication tries the running ssh-agent, then the
+// unencrypted default keys in ~/.ssh, then a password given in the URL.
+func openSFTP(base string) (treeFS, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		// url.Error repeats the URL, password included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid sftp URL %s: %w", redactRoot(base), err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid sftp URL %s: no host", redactRoot(base))
+	}
+	username := u.User.Username()
+	if username == "" {
+		if cur, err := user.Current(); err == nil {
+			username = cur.Username
+		}
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	root := u.Path
+	switch {
+	case root == "" || root == "/~":
+		root = "."
+	case strings.HasPrefix(root, "/~/"):
+		root = root[len("/~/"):]
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %w", err)
+	}
+	auth, agentConn := sshAuth(home)
+	if password, ok := u.User.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		closeAgent(agentConn)
+		return nil, fmt.Errorf("no SSH credentials for %s: start ssh-agent or add an unencrypted key to ~/.ssh", u.Hostname())
+	}
+
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		closeAgent(agentConn)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("%s is not in ~/.ssh/known_hosts; connect once with ssh to add it", u.Hostname())
+		}
+		return nil, fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		closeAgent(agentConn)
+		return nil, fmt.Errorf("starting sftp on %s: %w", addr, err)
+	}
+	return &sftpFS{conn: conn, client: client, agent: agentConn, root: root}, nil
+}
+
+// sshAuth collects the agent's keys and any default key files that load
+// without a passphrase. Agent keys sign through the returned connection,
+// which must stay open as long as the SSH session; it is nil when the
+// agent contributed no keys.
+func sshAuth(home string) ([]ssh.AuthMethod, net.Conn) {
+	var signers []ssh.Signer
+	var agentConn net.Conn
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if c, err := net.Dial("unix", sock); err == nil {
+			if s, err := agent.NewClient(c).Signers(); err == nil && len(s) > 0 {
+				signers = append(signers, s...)
+				agentConn = c
+			} else {
+				c.Close()
+			}
+		}
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pem, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if s, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, nil
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, agentConn
+}
+
+// closeAgent closes an ssh-agent connection from sshAuth, if there is one.
+func closeAgent(c net.Conn) {
+	if c != nil {
+		c.Close()
+	}
+}
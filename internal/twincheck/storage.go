@@ -0,0 +1,123 @@
+package twincheck
+
+import "fmt"
+
+// storageStats sizes the redundancy between two hashed trees. Hardlinked
+// paths are counted once, as they occupy space once.
+type storageStats struct {
+	bytesA, bytesB int64 // space each tree uses now
+	shared         int64 // content present in both trees, one copy
+	merged         int64 // every distinct content once: a merged, deduplicated tree
+	estimate       bool  // from a comparison that hashed only some files
+}
+
+// measureStorage computes storageStats from the content hashes of both
+// trees. Paths absent from the hashes, such as unreadable files, are left
+// out.
+func measureStorage(a *tree, hashesA map[string]string, b *tree, hashesB map[string]string) storageStats {
+	var s storageStats
+	sizes := make(map[string]int64)
+	inA := make(map[string]bool)
+	for p, h := range hashesA {
+		if _, alias := a.links[p]; alias {
+			continue
+		}
+		s.bytesA += a.files[p]
+		sizes[h] = a.files[p]
+		inA[h] = true
+	}
+	counted := make(map[string]bool)
+	for p, h := range hashesB {
+		if _, alias := b.links[p]; alias {
+			continue
+		}
+		s.bytesB += b.files[p]
+		sizes[h] = b.files[p]
+		if inA[h] && !counted[h] {
+			counted[h] = true
+			s.shared += b.files[p]
+		}
+	}
+	for _, size := range sizes {
+		s.merged += size
+	}
+	return s
+}
+
+// estimateStorage computes storageStats after a --hash-mode smart|strict
+// or --compare content|both run from the hashes it already computed.
+// Those runs hash only files that could match something in the other tree,
+// so the rest is keyed by what the comparison concluded: an unhashed file
+// at a path the other tree holds with the same size shares its content, as
+// smart mode and --hash-ext assume, unless the content pass found the pair
+// to differ; any other unhashed file counts as content of its own, so
+// duplicates among those are missed.
+func estimateStorage(a, b *tree, res result) storageStats {
+	keysA := make(map[string]string, len(a.files))
+	keysB := make(map[string]string, len(b.files))
+	for p := range a.files {
+		if _, bad := res.errsA[p]; !bad {
+			keysA[p] = res.hashesA[p]
+		}
+	}
+	for p := range b.files {
+		if _, bad := res.errsB[p]; !bad {
+			keysB[p] = res.hashesB[p]
+		}
+	}
+	for p, kA := range keysA {
+		kB, ok := keysB[p]
+		if !ok || a.files[p] != b.files[p] || res.differs[p] || (kA != "" && kB != "") {
+			continue
+		}
+		switch {
+		case kA != "":
+			keysB[p] = kA
+		case kB != "":
+			keysA[p] = kB
+		default:
+			keysA[p], keysB[p] = "same\x00"+p, "same\x00"+p
+		}
+	}
+	for p, k := range keysA {
+		if k == "" {
+			keysA[p] = "A\x00" + p
+		}
+	}
+	for p, k := range keysB {
+		if k == "" {
+			keysB[p] = "B\x00" + p
+		}
+	}
+	s := measureStorage(a, keysA, b, keysB)
+	s.estimate = true
+	return s
+}
+
+// write prints the consolidation summary.
+func (s storageStats) write(opts options) {
+	output(opts.outFile, "\n=== Storage ===")
+	output(opts.outFile, fmt.Sprintf("Tree A uses %s, Tree B uses %s (%s together).", humanSize(s.bytesA), humanSize(s.bytesB), humanSize(s.bytesA+s.bytesB)))
+	if s.estimate {
+		output(opts.outFile, fmt.Sprintf("About %s of content exists in both trees.", humanSize(s.shared)))
+		output(opts.outFile, fmt.Sprintf("Merging them into one deduplicated tree would need about %s; deduplicating would free about %s.", humanSize(s.merged), humanSize(s.bytesA+s.bytesB-s.merged)))
+		output(opts.outFile, "Estimated from the files this comparison hashed; --by-content measures exactly.")
+		return
+	}
+	output(opts.outFile, fmt.Sprintf("%s of content exists in both trees.", humanSize(s.shared)))
+	output(opts.outFile, fmt.Sprintf("Merging them into one deduplicated tree would need %s; deduplicating would free %s.", humanSize(s.merged), humanSize(s.bytesA+s.bytesB-s.merged)))
+}
+
+// humanSize formats bytes with a binary unit, e.g. "2.1 TB".
+func humanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,41 @@
+package twincheck
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateStorageMatchesFullHash(t *testing.T) {
+	dir := t.TempDir()
+	x, y := filepath.Join(dir, "x"), filepath.Join(dir, "y")
+	writeRandomFile(t, x, "same.bin", 1000)
+	writeRandomFile(t, y, "same.bin", 1000)
+	writeRandomFile(t, x, filepath.Join("old", "moved.bin"), 2000)
+	writeRandomFile(t, y, filepath.Join("new", "moved.bin"), 2000)
+	writeRandomFile(t, x, "only-x.bin", 3000)
+	writeRandomFile(t, y, "only-y.bin", 4000)
+
+	opts := options{mode: "all", compare: "both"}
+	a, _, _, err := loadTree(localFS{x}, x, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, _, err := loadTree(localFS{y}, y, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashesA, _, hashesB, _ := hashPair(a, a.allPaths(), b, b.allPaths(), opts)
+	want := measureStorage(a, hashesA, b, hashesB)
+
+	for _, mode := range []string{"smart", "strict"} {
+		res, err := compare(a, b, mode, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := estimateStorage(a, b, res)
+		got.estimate = false
+		if got != want {
+			t.Errorf("hash mode %s: estimate %+v, full hash %+v", mode, got, want)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package twincheck
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,73 +17,402 @@ import (
 
 type FileMap map[string]int64
 
-func getFilesConcurrent(base string) (FileMap, error) {
+// linkMap maps a hardlinked path to the canonical path of the same inode
+// within one tree. Canonical paths themselves are not keys.
+type linkMap map[string]string
+
+func (l linkMap) canonical(rel string) string {
+	if c, ok := l[rel]; ok {
+		return c
+	}
+	return rel
+}
+
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+// options carries the run-wide settings shared by all comparison modes.
+type options struct {
+	mode           string // all | missing_a | missing_b
+	compare        string // structure | content | both
+	outFile        *os.File
+	limit          int
+	hardlinks      bool // collapse hardlinks within a tree into one file
+	byName         bool // match on base name + size instead of relative path
+	intraDup       bool // strict: also report same-content groups within each tree
+	readBuffer     int  // bytes read per hashing I/O call
+	ignore         ignoreRules
+	skip           skipRules        // applied to both trees at once, by applySkip
+	emit           func(diffRecord) // --format jsonl: stream differences instead of collecting them
+	hashExt        extPolicy        // smart: which extensions may be hashed
+	filter         pathFilter       // report only differences under this subtree
+	normalize      bool             // key paths by their Unicode NFC form
+	minFiles       int              // refuse trees with fewer files (likely a wrong path)
+	sizeTolerance  float64          // percent within which same-path files count as matching
+	fuzzyNames     bool             // pair leftover one-sided files by accent- and punctuation-insensitive path
+	parallelDrives bool             // hash A and B at the same time
+	hashWorkersA   int              // files hashed in parallel on Tree A's drive
+	hashWorkersB   int
+	showPath       string // a | b | both: side printed for moved, changed and near-match entries
+	quickCompare   bool   // content: read same-size pairs side by side instead of hashing
+	granularity    string // file | dir | top: level one-sided entries are reported at
+}
+
+// checkMinFiles refuses a tree that holds fewer than --min-files files,
+// which usually means a mistyped path or an unmounted drive rather than a
+// real difference.
+func checkMinFiles(t *tree, opts options) error {
+	if len(t.files) >= opts.minFiles {
+		return nil
+	}
+	return fmt.Errorf("%s has only %d files, fewer than --min-files %d; check the path and that the drive is mounted", t.base, len(t.files), opts.minFiles)
+}
+
+// getFilesConcurrent lists every file under base with its size. It also
+// returns the directories that could not be read for lack of permission;
+// other unreadable directories are skipped silently.
+func getFilesConcurrent(fsys treeFS, base string, trackLinks bool) (FileMap, linkMap, []string, error) {
+	info, err := fsys.Stat(".")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil, nil, fmt.Errorf("%s is not a directory", base)
+	}
+
 	files := make(FileMap)
+	inodes := make(map[inode][]string)
+	var denied []string
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
 	var scanDir func(string)
 	scanDir = func(current string) {
 		defer wg.Done()
-		entries, err := os.ReadDir(current)
+		entries, err := fsys.ReadDir(current)
 		if err != nil {
+			if isPermission(err) {
+				mu.Lock()
+				denied = append(denied, current)
+				mu.Unlock()
+			}
 			return
 		}
+		// Collect this directory locally and merge it under one lock, so
+		// wide trees don't contend on the shared maps for every file
+		local := make(FileMap, len(entries))
+		var ids []inode
+		var idPaths []string
 		for _, entry := range entries {
-			fullPath := filepath.Join(current, entry.Name())
+			rel := filepath.Join(current, entry.Name())
 			if entry.IsDir() {
 				wg.Add(1)
-				go scanDir(fullPath)
+				go scanDir(rel)
 			} else {
 				info, err := entry.Info()
 				if err != nil {
 					continue
 				}
-				rel, err := filepath.Rel(base, fullPath)
-				if err != nil {
-					continue
+				local[rel] = info.Size()
+				if trackLinks {
+					if id, ok := inodeOf(info); ok {
+						ids = append(ids, id)
+						idPaths = append(idPaths, rel)
+					}
 				}
-				mu.Lock()
-				files[rel] = info.Size()
-				mu.Unlock()
 			}
 		}
+		if len(local) == 0 {
+			return
+		}
+		mu.Lock()
+		for rel, size := range local {
+			files[rel] = size
+		}
+		for i, id := range ids {
+			inodes[id] = append(inodes[id], idPaths[i])
+		}
+		mu.Unlock()
+	}
+
+	wg.Add(1)
+	scanDir(".")
+	wg.Wait()
+
+	links := make(linkMap)
+	for _, paths := range inodes {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		for _, p := range paths[1:] {
+			links[p] = paths[0]
+		}
 	}
+	sort.Strings(denied)
+	return files, links, denied, nil
+}
 
+// tree is one side of a comparison: either a live directory or a manifest.
+type tree struct {
+	base     string
+	fsys     treeFS // where a live tree is read from; nil for manifests
+	files    FileMap
+	links    linkMap
+	disk     diskNames         // on-disk names of paths rewritten by --normalize-unicode
+	hashes   map[string]string // precomputed hashes (manifest); nil for live trees
+	cache    *hashCache        // optional cache reused across watch iterations
+	bufSz    int               // per-worker read buffer for hashing
+	workers  int               // files hashed in parallel on this tree's drive
+	progress *hashProgress     // counts bytes hashed during hashPair; nil otherwise
+	denied   []string          // directories skipped for lack of permission
+}
+
+// errNotInManifest marks a manifest entry saved without a hash because
+// the file could not be read at the time.
+var errNotInManifest = errors.New("no hash recorded in manifest")
+
+// hash returns content hashes for paths, reading from disk for live trees.
+// Paths that cannot be hashed are absent from hashes and listed in errs.
+func (t *tree) hash(paths []string) (hashes map[string]string, errs map[string]error) {
+	if t.hashes == nil {
+		return hashFiles(t.fsys, paths, t.links, t.disk, t.cache, t.bufSz, t.workers, t.progress)
+	}
+	hashes = make(map[string]string, len(paths))
+	errs = make(map[string]error)
+	for _, p := range paths {
+		if h, ok := t.hashes[p]; ok {
+			hashes[p] = h
+		} else {
+			errs[p] = errNotInManifest
+		}
+	}
+	return hashes, errs
+}
+
+// hashPair hashes pathsA in a and pathsB in b. With --parallel-drives the
+// two passes overlap, which helps when the trees are on separate disks but
+// only adds seeking when they share one, so by default they run in turn.
+// On a terminal, progress is shown as a share of the bytes both passes
+// will read.
+func hashPair(a *tree, pathsA []string, b *tree, pathsB []string, opts options) (hashesA map[string]string, errsA map[string]error, hashesB map[string]string, errsB map[string]error) {
+	progress := startProgress(a.hashBytes(pathsA) + b.hashBytes(pathsB))
+	a.progress, b.progress = progress, progress
+	defer func() {
+		progress.finish()
+		a.progress, b.progress = nil, nil
+	}()
+	if !opts.parallelDrives {
+		hashesA, errsA = a.hash(pathsA)
+		hashesB, errsB = b.hash(pathsB)
+		return
+	}
+	var wg sync.WaitGroup
 	wg.Add(1)
-	scanDir(base)
+	go func() {
+		defer wg.Done()
+		hashesA, errsA = a.hash(pathsA)
+	}()
+	hashesB, errsB = b.hash(pathsB)
+	wg.Wait()
+	return
+}
+
+// allPaths returns every path in the tree.
+func (t *tree) allPaths() []string {
+	paths := make([]string, 0, len(t.files))
+	for p := range t.files {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// close releases the connection behind a remote tree.
+func (t *tree) close() {
+	if t != nil && t.fsys != nil {
+		t.fsys.Close()
+	}
+}
+
+// scanTree scans base and reports the number of distinct files found.
+func scanTree(base string, opts options) (*tree, error) {
+	output(opts.outFile, fmt.Sprintf("Scanning %s...", redactRoot(base)))
+	t, excluded, collisions, err := openTree(base, opts)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", redactRoot(base), err)
+	}
+	reportTree(t, excluded, collisions, opts)
+	if err := checkMinFiles(t, opts); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// scanTreePair scans two trees concurrently, which roughly halves the wall
+// time when they live on different drives. Results are reported in A, B
+// order once both are done.
+func scanTreePair(baseA, baseB string, opts options) (*tree, *tree, error) {
+	output(opts.outFile, fmt.Sprintf("Scanning %s and %s...", redactRoot(baseA), redactRoot(baseB)))
+	var a, b *tree
+	var exclA, exclB []int
+	var collA, collB int
+	var errA, errB error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		a, exclA, collA, errA = openTree(baseA, opts)
+	}()
+	go func() {
+		defer wg.Done()
+		b, exclB, collB, errB = openTree(baseB, opts)
+	}()
 	wg.Wait()
-	return files, nil
+
+	if errA != nil || errB != nil {
+		a.close()
+		b.close()
+		if errA != nil {
+			return nil, nil, fmt.Errorf("scanning %s: %w", redactRoot(baseA), errA)
+		}
+		return nil, nil, fmt.Errorf("scanning %s: %w", redactRoot(baseB), errB)
+	}
+	reportTree(a, exclA, collA, opts)
+	reportTree(b, exclB, collB, opts)
+	for _, t := range []*tree{a, b} {
+		if err := checkMinFiles(t, opts); err != nil {
+			a.close()
+			b.close()
+			return nil, nil, err
+		}
+	}
+	return a, b, nil
+}
+
+// openTree opens the backend for base (a local directory or a remote URL)
+// and loads the tree from it.
+func openTree(base string, opts options) (*tree, []int, int, error) {
+	fsys, err := openTreeFS(base)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	// The tree keeps the redacted root: it is what gets printed and saved
+	t, excluded, collisions, err := loadTree(fsys, redactRoot(base), opts)
+	if err != nil {
+		fsys.Close()
+	}
+	return t, excluded, collisions, err
+}
+
+// loadTree scans base through fsys, normalizes names if asked and applies
+// the ignore rules without printing.
+func loadTree(fsys treeFS, base string, opts options) (*tree, []int, int, error) {
+	files, links, denied, err := getFilesConcurrent(fsys, base, opts.hardlinks)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var disk diskNames
+	collisions := 0
+	if opts.normalize {
+		disk, collisions = normalizeNames(files, links)
+	}
+	excluded := opts.ignore.apply(files, links)
+	return &tree{base: base, fsys: fsys, files: files, links: links, disk: disk, bufSz: opts.readBuffer, denied: denied}, excluded, collisions, nil
+}
+
+// applySkip applies the --skip rules to a and b, which may be nil.
+func applySkip(a, b *tree, opts options) []int {
+	var filesB FileMap
+	var linksB linkMap
+	if b != nil {
+		filesB, linksB = b.files, b.links
+	}
+	return opts.skip.apply(a.files, a.links, filesB, linksB)
+}
+
+func reportTree(t *tree, excluded []int, collisions int, opts options) {
+	if len(t.links) > 0 {
+		output(opts.outFile, fmt.Sprintf("Found %d files in %s (+%d hardlinked paths)", len(t.files)-len(t.links), t.base, len(t.links)))
+	} else {
+		output(opts.outFile, fmt.Sprintf("Found %d files in %s", len(t.files), t.base))
+	}
+	if s := opts.ignore.summary(excluded); s != "" {
+		output(opts.outFile, "  "+s)
+	}
+	if collisions > 0 {
+		output(opts.outFile, fmt.Sprintf("  %d names normalize to a path already present and were kept as is", collisions))
+	}
 }
 
-func hashFile(path string) (string, error) {
-	f, err := os.Open(path)
+// defaultReadBuffer is the hashing read size; larger than io.Copy's 32 KB
+// default to keep fast storage busy on big files.
+const defaultReadBuffer = 1 << 20
+
+// defaultHashWorkers suits SSDs and network storage; a spinning disk does
+// better with a handful of readers (--hash-workers-a/-b).
+const defaultHashWorkers = 32
+
+func hashFile(fsys treeFS, rel string, buf []byte, progress *hashProgress) (string, error) {
+	f, err := fsys.Open(rel)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
+	var sink io.Writer = h
+	if progress != nil {
+		sink = io.MultiWriter(h, progress)
+	}
+	// Hide the file's WriteTo so io.CopyBuffer actually uses buf
+	if _, err := io.CopyBuffer(sink, struct{ io.Reader }{f}, buf); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func hashFiles(base string, paths []string) map[string]string {
+// hashFiles hashes the given paths, reading each hardlinked inode only once.
+// cache may be nil. Up to workers files are read at once, and each worker
+// reuses one read buffer of bufSize bytes.
+// Files that could not be read are returned in errs rather than hashes, so
+// callers can tell "unreadable" apart from "different content". Paths are
+// opened through fsys under their on-disk names from disk. progress, which
+// may be nil, counts the bytes read.
+func hashFiles(fsys treeFS, paths []string, links linkMap, disk diskNames, cache *hashCache, bufSize, workers int, progress *hashProgress) (hashes map[string]string, errs map[string]error) {
+	if bufSize <= 0 {
+		bufSize = defaultReadBuffer
+	}
+	if workers <= 0 {
+		workers = defaultHashWorkers
+	}
 	if len(paths) == 0 {
-		return make(map[string]string)
+		return make(map[string]string), make(map[string]error)
+	}
+
+	requested := paths
+	seen := make(map[string]bool, len(paths))
+	paths = make([]string, 0, len(requested))
+	for _, p := range requested {
+		c := links.canonical(p)
+		if !seen[c] {
+			seen[c] = true
+			paths = append(paths, c)
+		}
 	}
 
-	numWorkers := 32 // adjust based on your system; 32 is safe for I/O
+	numWorkers := workers
 	if len(paths) < numWorkers {
 		numWorkers = len(paths)
 	}
 
 	jobs := make(chan string, len(paths))
-	results := make(chan struct {
+	type hashResult struct {
 		path string
 		hash string
-	}, len(paths))
+		err  error
+	}
+	results := make(chan hashResult, len(paths))
 
 	var wg sync.WaitGroup
 
@@ -90,13 +421,10 @@ func hashFiles(base string, paths []string) map[string]string {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			buf := make([]byte, bufSize)
 			for rel := range jobs {
-				if h, err := hashFile(filepath.Join(base, rel)); err == nil {
-					results <- struct {
-						path string
-						hash string
-					}{rel, h}
-				}
+				h, err := cache.hashFile(fsys, disk.onDisk(rel), buf, progress)
+				results <- hashResult{rel, h, err}
 			}
 		}()
 	}
@@ -116,12 +444,22 @@ func hashFiles(base string, paths []string) map[string]string {
 	}()
 
 	// Collect results
-	hashes := make(map[string]string)
+	byCanonical := make(map[string]hashResult)
 	for res := range results {
-		hashes[res.path] = res.hash
+		byCanonical[res.path] = res
 	}
 
-	return hashes
+	hashes = make(map[string]string, len(requested))
+	errs = make(map[string]error)
+	for _, p := range requested {
+		res := byCanonical[links.canonical(p)]
+		if res.err != nil {
+			errs[p] = res.err
+		} else {
+			hashes[p] = res.hash
+		}
+	}
+	return hashes, errs
 }
 
 func buildSizeMap(fm FileMap) map[int64][]string {
@@ -140,80 +478,260 @@ func output(outFile *os.File, s string) {
 	}
 }
 
-// === Mode: off ===
-func compareOff(filesA, filesB FileMap, mode string, outFile *os.File) {
-	var onlyA, onlyB []string
-	for path := range filesA {
-		if _, ok := filesB[path]; !ok {
-			onlyA = append(onlyA, path)
+// outputSection prints a titled list. The output file always receives the
+// full list; the console is truncated to limit entries (0 = no limit). When
+// writing to a file, the console only gets a summary if a limit is set.
+func outputSection(outFile *os.File, title string, items []string, limit int) {
+	header := fmt.Sprintf("\n=== %s (%d) ===", title, len(items))
+	if outFile != nil {
+		fmt.Fprintln(outFile, header)
+		for _, f := range items {
+			fmt.Fprintln(outFile, f)
 		}
-	}
-	for path := range filesB {
-		if _, ok := filesA[path]; !ok {
-			onlyB = append(onlyB, path)
+		if limit <= 0 {
+			return
 		}
 	}
-	sort.Strings(onlyA)
-	sort.Strings(onlyB)
 
-	switch mode {
+	fmt.Println(header)
+	shown := items
+	if limit > 0 && len(items) > limit {
+		shown = items[:limit]
+	}
+	for _, f := range shown {
+		fmt.Println(f)
+	}
+	if len(shown) < len(items) {
+		fmt.Printf("... and %d more\n", len(items)-len(shown))
+	}
+}
+
+// result is the outcome of comparing two trees.
+type result struct {
+	onlyA      []string
+	onlyB      []string
+	moved      []string // "A path -> B path" pairs with identical content
+	near       []string // pairs whose sizes are within --size-tolerance
+	fuzzy      []string // pairs whose paths agree under --fuzzy-names
+	changed    []string // same path in both trees, different content (--compare content|both)
+	intraA     []string // same-content groups within A (strict + --report-intra-dupes)
+	intraB     []string
+	unreadable []string         // files that could not be hashed, with the reason
+	errsA      map[string]error // the hashing failures behind unreadable, so --compare both reports each once
+	errsB      map[string]error
+	hashesA    map[string]string // content hashes the comparison computed, for the storage summary
+	hashesB    map[string]string
+	differs    map[string]bool // same-size pairs at one path found to differ (--compare content|both)
+	diffs      int             // differences found, including any streamed with --format jsonl
+	byStatus   map[string]int  // diffs per diffRecord status, for --fail-on
+}
+
+// mergeHashes copies src into dst, allocating dst if needed.
+func mergeHashes(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for p, h := range src {
+		dst[p] = h
+	}
+	return dst
+}
+
+// mergeErrs copies src into dst, allocating dst if needed.
+func mergeErrs(dst, src map[string]error) map[string]error {
+	if dst == nil {
+		dst = make(map[string]error, len(src))
+	}
+	for p, err := range src {
+		dst[p] = err
+	}
+	return dst
+}
+
+// report prints a comparison result.
+func report(res result, opts options) {
+	printResults(aggregate(res.onlyA, opts.granularity), aggregate(res.onlyB, opts.granularity), opts)
+	if len(res.unreadable) > 0 {
+		outputSection(opts.outFile, "Unreadable (could not hash)", res.unreadable, opts.limit)
+	}
+	if opts.compare != "structure" {
+		outputSection(opts.outFile, "Content differs", res.changed, opts.limit)
+	}
+	if len(res.moved) > 0 {
+		outputSection(opts.outFile, "Moved/renamed", res.moved, opts.limit)
+	}
+	if len(res.near) > 0 {
+		outputSection(opts.outFile, fmt.Sprintf("Near matches (sizes within %g%%, content not compared)", opts.sizeTolerance), res.near, opts.limit)
+	}
+	if len(res.fuzzy) > 0 {
+		outputSection(opts.outFile, "Probable matches (name normalized, FUZZY: content not compared)", res.fuzzy, opts.limit)
+	}
+	if opts.intraDup {
+		outputSection(opts.outFile, "Duplicate groups within Tree A", res.intraA, opts.limit)
+		outputSection(opts.outFile, "Duplicate groups within Tree B", res.intraB, opts.limit)
+	}
+}
+
+// compare runs the comparisons --compare asks for: the structure
+// comparison for the given hash mode, the content comparison of shared
+// paths, or both.
+func compare(a, b *tree, hashMode string, opts options) (result, error) {
+	if opts.compare == "content" {
+		return compareContent(a, b, opts, nil, nil), nil
+	}
+	res, err := compareStructure(a, b, hashMode, opts)
+	if err != nil || opts.compare != "both" {
+		return res, err
+	}
+	return mergeContent(res, compareContent(a, b, opts, res.errsA, res.errsB)), nil
+}
+
+// compareStructure dispatches to the comparison for the given hash mode.
+func compareStructure(a, b *tree, hashMode string, opts options) (result, error) {
+	switch hashMode {
+	case "off":
+		return compareOff(a, b, opts), nil
+	case "smart":
+		return compareSmart(a, b, opts)
+	case "strict":
+		return compareStrict(a, b, opts)
+	}
+	return result{}, fmt.Errorf("invalid hash-mode: %s (use: off, smart, strict)", hashMode)
+}
+
+// printResults reports the files found only in A and only in B according
+// to the selected comparison mode, already aggregated to --granularity.
+func printResults(onlyA, onlyB []string, opts options) {
+	by := granularityTitle(opts.granularity)
+	switch opts.mode {
 	case "missing_a":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree A (%d) ===", len(onlyB)))
-		for _, f := range onlyB {
-			output(outFile, f)
-		}
+		outputSection(opts.outFile, "Files missing in Tree A"+by, onlyB, opts.limit)
 	case "missing_b":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree B (%d) ===", len(onlyA)))
-		for _, f := range onlyA {
-			output(outFile, f)
-		}
+		outputSection(opts.outFile, "Files missing in Tree B"+by, onlyA, opts.limit)
 	case "all":
 		if len(onlyA) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree A (%d) ===", len(onlyA)))
-			for _, f := range onlyA {
-				output(outFile, f)
-			}
+			outputSection(opts.outFile, "Only in Tree A"+by, onlyA, opts.limit)
 		}
 		if len(onlyB) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree B (%d) ===", len(onlyB)))
-			for _, f := range onlyB {
-				output(outFile, f)
-			}
+			outputSection(opts.outFile, "Only in Tree B"+by, onlyB, opts.limit)
 		}
 	}
 }
 
-// === Mode: smart (your preferred) ===
-func compareSmart(driveA, driveB string, mode string, outFile *os.File) error {
-	output(outFile, fmt.Sprintf("Scanning %s...", driveA))
-	filesA, _ := getFilesConcurrent(driveA)
-	output(outFile, fmt.Sprintf("Found %d files in %s", len(filesA), driveA))
+// nameKey identifies a file by base name and size, ignoring its directory.
+func nameKey(path string, size int64) string {
+	return fmt.Sprintf("%s|%d", filepath.Base(path), size)
+}
 
-	output(outFile, fmt.Sprintf("Scanning %s...", driveB))
-	filesB, _ := getFilesConcurrent(driveB)
-	output(outFile, fmt.Sprintf("Found %d files in %s", len(filesB), driveB))
+// missingFrom returns the paths of files that have no counterpart in other,
+// either by relative path or, with byName, by base name + size anywhere.
+func missingFrom(files, other FileMap, byName bool) []string {
+	var missing []string
+	if !byName {
+		for path := range files {
+			if _, ok := other[path]; !ok {
+				missing = append(missing, path)
+			}
+		}
+		return missing
+	}
 
-	var missingInB, missingInA []string
-	for path := range filesA {
-		if _, ok := filesB[path]; !ok {
-			missingInB = append(missingInB, path)
+	names := make(map[string]bool, len(other))
+	for path, size := range other {
+		names[nameKey(path, size)] = true
+	}
+	for path, size := range files {
+		if !names[nameKey(path, size)] {
+			missing = append(missing, path)
 		}
 	}
-	for path := range filesB {
-		if _, ok := filesA[path]; !ok {
-			missingInA = append(missingInA, path)
+	return missing
+}
+
+// detectMoves pairs files missing by path on each side that share content,
+// recording them as "old -> new" instead of as unrelated one-sided entries.
+// Hashes only need to cover the missing paths; unhashed paths are ignored.
+func detectMoves(rec *recorder, missingA []string, hashesA map[string]string, missingB []string, hashesB map[string]string) {
+	byHash := make(map[string][]string)
+	for _, p := range missingB {
+		if h, ok := hashesB[p]; ok {
+			byHash[h] = append(byHash[h], p)
+		}
+	}
+	for _, paths := range byHash {
+		sort.Strings(paths)
+	}
+
+	sorted := append([]string(nil), missingA...)
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		h, ok := hashesA[p]
+		if !ok || len(byHash[h]) == 0 {
+			continue
 		}
+		rec.moved(p, byHash[h][0])
+		byHash[h] = byHash[h][1:]
+	}
+}
+
+// === Mode: off ===
+func compareOff(a, b *tree, opts options) result {
+	rec := newRecorder(a, b, opts)
+	if reportSamePathNear(opts) {
+		rec.samePathNear()
+	}
+	for _, p := range missingFrom(a.files, b.files, opts.byName) {
+		rec.onlyA(p)
 	}
+	for _, p := range missingFrom(b.files, a.files, opts.byName) {
+		rec.onlyB(p)
+	}
+	return rec.finish()
+}
+
+// reportSamePathNear reports whether off or smart mode must list same-path
+// size drift within --size-tolerance itself. --by-name leaves such files
+// one-sided for pairNear, and --compare both has the content pass find them.
+func reportSamePathNear(opts options) bool {
+	return opts.sizeTolerance > 0 && !opts.byName && opts.compare != "both"
+}
+
+// === Mode: smart (your preferred) ===
+func compareSmart(a, b *tree, opts options) (result, error) {
+	filesA, filesB := a.files, b.files
 
+	missingInB := missingFrom(filesA, filesB, opts.byName)
+	missingInA := missingFrom(filesB, filesA, opts.byName)
+
+	// Only files the extension policy lets us hash can confirm a match
 	sizeMapB := buildSizeMap(filesB)
 	sizeMapA := buildSizeMap(filesA)
+	if opts.hashExt.active() {
+		for size, paths := range sizeMapB {
+			sizeMapB[size] = opts.hashExt.hashablePaths(paths)
+		}
+		for size, paths := range sizeMapA {
+			sizeMapA[size] = opts.hashExt.hashablePaths(paths)
+		}
+	}
 
-	var trulyMissingInB, trulyMissingInA []string
+	rec := newRecorder(a, b, opts)
+	rec.widen = true
+	if reportSamePathNear(opts) {
+		rec.samePathNear()
+	}
+	var missingHashesA, missingHashesB map[string]string
+	var allHashesA, allHashesB map[string]string
+	var errsA, errsB map[string]error
 
 	// Process missingInB
 	if len(missingInB) > 0 {
 		missingBySize := make(map[int64][]string)
 		for _, p := range missingInB {
+			if !opts.hashExt.hashable(p) {
+				rec.onlyA(p)
+				continue
+			}
 			missingBySize[filesA[p]] = append(missingBySize[filesA[p]], p)
 		}
 
@@ -223,24 +741,24 @@ func compareSmart(driveA, driveB string, mode string, outFile *os.File) error {
 				toHashA = append(toHashA, paths...)
 				toHashB = append(toHashB, candidates...)
 			} else {
-				trulyMissingInB = append(trulyMissingInB, paths...)
+				for _, p := range paths {
+					rec.onlyA(p)
+				}
 			}
 		}
 
 		if len(toHashA) > 0 {
-			hashesA := hashFiles(driveA, toHashA)
-			hashesB := hashFiles(driveB, toHashB)
+			hashesA, badA, hashesB, badB := hashPair(a, toHashA, b, toHashB, opts)
+			errsA, errsB = mergeErrs(errsA, badA), mergeErrs(errsB, badB)
+			allHashesA, allHashesB = mergeHashes(allHashesA, hashesA), mergeHashes(allHashesB, hashesB)
+			missingHashesA = hashesA
 			hashSetB := make(map[string]bool)
 			for _, h := range hashesB {
 				hashSetB[h] = true
 			}
 			for _, p := range toHashA {
-				if h, ok := hashesA[p]; ok {
-					if !hashSetB[h] {
-						trulyMissingInB = append(trulyMissingInB, p)
-					}
-				} else {
-					trulyMissingInB = append(trulyMissingInB, p)
+				if h, ok := hashesA[p]; ok && !hashSetB[h] {
+					rec.onlyA(p)
 				}
 			}
 		}
@@ -250,6 +768,10 @@ func compareSmart(driveA, driveB string, mode string, outFile *os.File) error {
 	if len(missingInA) > 0 {
 		missingBySize := make(map[int64][]string)
 		for _, p := range missingInA {
+			if !opts.hashExt.hashable(p) {
+				rec.onlyB(p)
+				continue
+			}
 			missingBySize[filesB[p]] = append(missingBySize[filesB[p]], p)
 		}
 
@@ -259,79 +781,41 @@ func compareSmart(driveA, driveB string, mode string, outFile *os.File) error {
 				toHashB2 = append(toHashB2, paths...)
 				toHashA2 = append(toHashA2, candidates...)
 			} else {
-				trulyMissingInA = append(trulyMissingInA, paths...)
+				for _, p := range paths {
+					rec.onlyB(p)
+				}
 			}
 		}
 
 		if len(toHashB2) > 0 {
-			hashesB := hashFiles(driveB, toHashB2)
-			hashesA := hashFiles(driveA, toHashA2)
+			hashesA, badA, hashesB, badB := hashPair(a, toHashA2, b, toHashB2, opts)
+			errsA, errsB = mergeErrs(errsA, badA), mergeErrs(errsB, badB)
+			allHashesA, allHashesB = mergeHashes(allHashesA, hashesA), mergeHashes(allHashesB, hashesB)
+			missingHashesB = hashesB
 			hashSetA := make(map[string]bool)
 			for _, h := range hashesA {
 				hashSetA[h] = true
 			}
 			for _, p := range toHashB2 {
-				if h, ok := hashesB[p]; ok {
-					if !hashSetA[h] {
-						trulyMissingInA = append(trulyMissingInA, p)
-					}
-				} else {
-					trulyMissingInA = append(trulyMissingInA, p)
+				if h, ok := hashesB[p]; ok && !hashSetA[h] {
+					rec.onlyB(p)
 				}
 			}
 		}
 	}
 
-	sort.Strings(trulyMissingInB)
-	sort.Strings(trulyMissingInA)
-
-	switch mode {
-	case "missing_a":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree A (%d) ===", len(trulyMissingInA)))
-		for _, f := range trulyMissingInA {
-			output(outFile, f)
-		}
-	case "missing_b":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree B (%d) ===", len(trulyMissingInB)))
-		for _, f := range trulyMissingInB {
-			output(outFile, f)
-		}
-	case "all":
-		if len(trulyMissingInB) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree A (%d) ===", len(trulyMissingInB)))
-			for _, f := range trulyMissingInB {
-				output(outFile, f)
-			}
-		}
-		if len(trulyMissingInA) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree B (%d) ===", len(trulyMissingInA)))
-			for _, f := range trulyMissingInA {
-				output(outFile, f)
-			}
-		}
-	}
-	return nil
+	detectMoves(rec, missingInB, missingHashesA, missingInA, missingHashesB)
+	rec.unreadable(errsA, errsB)
+	res := rec.finish()
+	res.hashesA, res.hashesB = allHashesA, allHashesB
+	return res, nil
 }
 
 // === Mode: strict (global content search) ===
-func compareStrict(driveA, driveB string, mode string, outFile *os.File) error {
-	output(outFile, fmt.Sprintf("Scanning %s...", driveA))
-	sizesA, _ := scanBySize(driveA)
-	totalA := 0
-	for _, paths := range sizesA {
-		totalA += len(paths)
-	}
-	output(outFile, fmt.Sprintf("Found %d files in %s", totalA, driveA))
-
-	output(outFile, fmt.Sprintf("Scanning %s...", driveB))
-	sizesB, _ := scanBySize(driveB)
-	totalB := 0
-	for _, paths := range sizesB {
-		totalB += len(paths)
-	}
-	output(outFile, fmt.Sprintf("Found %d files in %s", totalB, driveB))
+func compareStrict(a, b *tree, opts options) (result, error) {
+	sizesA := buildSizeMap(a.files)
+	sizesB := buildSizeMap(b.files)
 
-	// Now proceed with logic — no need to recompute totals
 	candidateSizes := make(map[int64]bool)
 	for size := range sizesA {
 		if len(sizesB[size]) > 0 {
@@ -351,8 +835,7 @@ func compareStrict(driveA, driveB string, mode string, outFile *os.File) error {
 		}
 	}
 
-	hashesA := hashFiles(driveA, candidatesA)
-	hashesB := hashFiles(driveB, candidatesB)
+	hashesA, errsA, hashesB, errsB := hashPair(a, candidatesA, b, candidatesB, opts)
 
 	hashSetB := make(map[string]bool)
 	for _, h := range hashesB {
@@ -363,107 +846,67 @@ func compareStrict(driveA, driveB string, mode string, outFile *os.File) error {
 		hashSetA[h] = true
 	}
 
-	var onlyA, onlyB []string
+	rec := newRecorder(a, b, opts)
 	for size, paths := range sizesA {
 		if len(sizesB[size]) == 0 {
-			onlyA = append(onlyA, paths...)
+			for _, path := range paths {
+				rec.onlyA(path)
+			}
 		} else {
 			for _, path := range paths {
-				if h, ok := hashesA[path]; ok {
-					if !hashSetB[h] {
-						onlyA = append(onlyA, path)
-					}
-				} else {
-					onlyA = append(onlyA, path)
+				if h, ok := hashesA[path]; ok && !hashSetB[h] {
+					rec.onlyA(path)
 				}
 			}
 		}
 	}
 	for size, paths := range sizesB {
 		if len(sizesA[size]) == 0 {
-			onlyB = append(onlyB, paths...)
+			for _, path := range paths {
+				rec.onlyB(path)
+			}
 		} else {
 			for _, path := range paths {
-				if h, ok := hashesB[path]; ok {
-					if !hashSetA[h] {
-						onlyB = append(onlyB, path)
-					}
-				} else {
-					onlyB = append(onlyB, path)
+				if h, ok := hashesB[path]; ok && !hashSetA[h] {
+					rec.onlyB(path)
 				}
 			}
 		}
 	}
 
-	sort.Strings(onlyA)
-	sort.Strings(onlyB)
-
-	switch mode {
-	case "missing_a":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree A (%d) ===", len(onlyB)))
-		for _, f := range onlyB {
-			output(outFile, f)
-		}
-	case "missing_b":
-		output(outFile, fmt.Sprintf("\n=== Files missing in Tree B (%d) ===", len(onlyA)))
-		for _, f := range onlyA {
-			output(outFile, f)
-		}
-	case "all":
-		if len(onlyA) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree A (%d) ===", len(onlyA)))
-			for _, f := range onlyA {
-				output(outFile, f)
-			}
-		}
-		if len(onlyB) > 0 {
-			output(outFile, fmt.Sprintf("\n=== Only in Tree B (%d) ===", len(onlyB)))
-			for _, f := range onlyB {
-				output(outFile, f)
-			}
-		}
+	detectMoves(rec, missingFrom(a.files, b.files, false), hashesA, missingFrom(b.files, a.files, false), hashesB)
+	rec.unreadable(errsA, errsB)
+	res := rec.finish()
+	res.hashesA, res.hashesB = hashesA, hashesB
+	if opts.intraDup {
+		res.intraA = intraDupes(hashesA, a.links)
+		res.intraB = intraDupes(hashesB, b.links)
 	}
-	return nil
+	return res, nil
 }
 
-// Helper for strict mode
-func scanBySize(base string) (map[int64][]string, error) {
-	groups := make(map[int64][]string)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	var scanDir func(string)
-	scanDir = func(current string) {
-		defer wg.Done()
-		entries, err := os.ReadDir(current)
-		if err != nil {
-			return
-		}
-		for _, entry := range entries {
-			fullPath := filepath.Join(current, entry.Name())
-			if entry.IsDir() {
-				wg.Add(1)
-				go scanDir(fullPath)
-			} else {
-				info, err := entry.Info()
-				if err != nil {
-					continue
-				}
-				rel, err := filepath.Rel(base, fullPath)
-				if err != nil {
-					continue
-				}
-				mu.Lock()
-				groups[info.Size()] = append(groups[info.Size()], rel)
-				mu.Unlock()
-			}
+// intraDupes lists groups of paths within one tree that share content,
+// based on hashes already computed. Hardlinks of the same inode are not
+// counted as duplicates.
+func intraDupes(hashes map[string]string, links linkMap) []string {
+	groups := make(map[string][]string)
+	for path, h := range hashes {
+		if _, alias := links[path]; alias {
+			continue
 		}
+		groups[h] = append(groups[h], path)
 	}
 
-	wg.Add(1)
-	scanDir(base)
-	wg.Wait()
-	return groups, nil
+	var items []string
+	for _, paths := range groups {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		items = append(items, strings.Join(paths, "\n  = "))
+	}
+	sort.Strings(items)
+	return items
 }
 
 // === Main run ===
@@ -474,6 +917,49 @@ func run(cmd *cobra.Command, args []string) error {
 	outPath, _ := cmd.Flags().GetString("out")
 	useHashFlag, _ := cmd.Flags().GetBool("hash")
 	hashMode, _ := cmd.Flags().GetString("hash-mode")
+	limit, _ := cmd.Flags().GetInt("limit")
+	noHardlinkDedup, _ := cmd.Flags().GetBool("no-hardlink-dedup")
+	byName, _ := cmd.Flags().GetBool("by-name")
+	dirDigest, _ := cmd.Flags().GetBool("dir-digest")
+	byContent, _ := cmd.Flags().GetBool("by-content")
+	saveManifestPath, _ := cmd.Flags().GetString("save-manifest")
+	compareManifest, _ := cmd.Flags().GetString("compare-manifest")
+	listA, _ := cmd.Flags().GetString("list-a")
+	listB, _ := cmd.Flags().GetString("list-b")
+	intraDup, _ := cmd.Flags().GetBool("report-intra-dupes")
+	watchMode, _ := cmd.Flags().GetBool("watch")
+	poll, _ := cmd.Flags().GetDuration("poll")
+	readBuffer, _ := cmd.Flags().GetInt("read-buffer")
+	hashWorkersA, _ := cmd.Flags().GetInt("hash-workers-a")
+	hashWorkersB, _ := cmd.Flags().GetInt("hash-workers-b")
+	ignoreGlobs, _ := cmd.Flags().GetStringArray("ignore")
+	excludeExts, _ := cmd.Flags().GetStringSlice("exclude-ext")
+	skipRules, _ := cmd.Flags().GetStringArray("skip")
+	format, _ := cmd.Flags().GetString("format")
+	failOnDiff, _ := cmd.Flags().GetBool("fail-on-diff")
+	failOn, _ := cmd.Flags().GetStringSlice("fail-on")
+	selfCheckPath, _ := cmd.Flags().GetString("self-check")
+	appendOut, _ := cmd.Flags().GetBool("append")
+	hashExts, _ := cmd.Flags().GetStringSlice("hash-ext")
+	noHashExts, _ := cmd.Flags().GetStringSlice("no-hash-ext")
+	filterPrefix, _ := cmd.Flags().GetString("filter-prefix")
+	normalize, _ := cmd.Flags().GetBool("normalize-unicode")
+	minFiles, _ := cmd.Flags().GetInt("min-files")
+	sizeTolerance, _ := cmd.Flags().GetFloat64("size-tolerance")
+	fuzzyNames, _ := cmd.Flags().GetBool("fuzzy-names")
+	parallelDrives, _ := cmd.Flags().GetBool("parallel-drives")
+	compareWhat, _ := cmd.Flags().GetString("compare")
+	showPath, _ := cmd.Flags().GetString("show-path")
+	quickCompare, _ := cmd.Flags().GetBool("quick-compare")
+	granularity, _ := cmd.Flags().GetString("granularity")
+
+	// Normalize how the roots were typed before anything is keyed on them
+	for _, root := range []*string{&driveA, &driveB} {
+		var err error
+		if *root, err = cleanRoot(*root); err != nil {
+			return err
+		}
+	}
 
 	// Resolve effective mode
 	effectiveMode := "off"
@@ -484,52 +970,353 @@ func run(cmd *cobra.Command, args []string) error {
 		effectiveMode = hashMode
 	}
 
-	if driveA == "" || driveB == "" {
-		return fmt.Errorf("both -a and -b flags are required")
+	if selfCheckPath != "" {
+		if driveA == "" || driveB != "" || compareManifest != "" || saveManifestPath != "" || dirDigest || watchMode || normalize {
+			return fmt.Errorf("--self-check takes -a only and cannot be combined with -b, manifests, --dir-digest, --watch or --normalize-unicode")
+		}
+		driveB = driveA // satisfies the two-tree checks below
+	}
+	if compareManifest != "" && (driveA != "" || saveManifestPath != "") {
+		return fmt.Errorf("--compare-manifest takes the place of -a and cannot be combined with -a or --save-manifest")
+	}
+	if listA != "" || listB != "" {
+		if selfCheckPath != "" || saveManifestPath != "" || watchMode {
+			return fmt.Errorf("--list-a and --list-b cannot be combined with --self-check, --save-manifest or --watch")
+		}
+		if listA != "" && compareManifest != "" {
+			return fmt.Errorf("--list-a and --compare-manifest both supply Tree A; use one")
+		}
+		needHashes := dirDigest || byContent || effectiveMode != "off" || compareWhat != "structure"
+		if needHashes && ((listA != "" && driveA == "") || (listB != "" && driveB == "")) {
+			return fmt.Errorf("hashing reads the listed files, so --list-a/--list-b need -a/-b as the root they are relative to")
+		}
+	}
+	if (driveA == "" && compareManifest == "" && listA == "") || (driveB == "" && saveManifestPath == "" && listB == "") {
+		return fmt.Errorf("both -a (or --compare-manifest or --list-a) and -b (or --list-b) flags are required")
+	}
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	if appendOut && outPath == "" {
+		return fmt.Errorf("--append requires --out")
+	}
+	if minFiles < 0 {
+		return fmt.Errorf("--min-files must not be negative")
+	}
+	if sizeTolerance < 0 || sizeTolerance >= 100 {
+		return fmt.Errorf("--size-tolerance must be a percentage from 0 up to 100")
+	}
+	if sizeTolerance > 0 && (dirDigest || selfCheckPath != "") {
+		return fmt.Errorf("--size-tolerance cannot be combined with --dir-digest or --self-check")
+	}
+	if fuzzyNames && (dirDigest || selfCheckPath != "" || byContent || compareWhat == "content") {
+		return fmt.Errorf("--fuzzy-names pairs files missing from one tree and cannot be combined with --dir-digest, --self-check, --by-content or --compare content")
+	}
+	if compareWhat != "structure" && compareWhat != "content" && compareWhat != "both" {
+		return fmt.Errorf("invalid --compare: %s (use: structure, content, both)", compareWhat)
+	}
+	if granularity != "file" && granularity != "dir" && granularity != "top" {
+		return fmt.Errorf("invalid --granularity: %s (use: file, dir, top)", granularity)
+	}
+	if granularity != "file" && (format != "text" || byContent || dirDigest) {
+		return fmt.Errorf("--granularity dir and top aggregate the text report and cannot be combined with --format jsonl, --by-content or --dir-digest")
+	}
+	if showPath != "a" && showPath != "b" && showPath != "both" {
+		return fmt.Errorf("invalid --show-path: %s (use: a, b, both)", showPath)
+	}
+	if compareWhat != "structure" && (byName || dirDigest || watchMode || selfCheckPath != "") {
+		return fmt.Errorf("--compare %s cannot be combined with --by-name, --dir-digest, --watch or --self-check", compareWhat)
+	}
+	if compareWhat == "content" && (mode != "all" || intraDup) {
+		return fmt.Errorf("--compare content ignores presence differences, so --mode and --report-intra-dupes do not apply")
+	}
+	if quickCompare && (compareWhat == "structure" || compareManifest != "") {
+		return fmt.Errorf("--quick-compare requires --compare content or both and two live trees")
+	}
+	if byContent && (dirDigest || byName || intraDup || watchMode || sizeTolerance > 0 || compareWhat != "structure" || selfCheckPath != "" || format != "text") {
+		return fmt.Errorf("--by-content is a comparison of its own and cannot be combined with --dir-digest, --by-name, --report-intra-dupes, --watch, --size-tolerance, --compare, --self-check or --format jsonl")
+	}
+	if byName && effectiveMode == "strict" {
+		return fmt.Errorf("--by-name applies to off and smart modes; strict already ignores paths")
+	}
+	if intraDup && (effectiveMode != "strict" || dirDigest) {
+		return fmt.Errorf("--report-intra-dupes requires --hash-mode strict")
+	}
+	failPol, err := parseFailOn(failOn)
+	if err != nil {
+		return err
+	}
+	if len(failOn) > 0 {
+		failOnDiff = true
+	}
+	if !failPol.any() && (byContent || dirDigest || selfCheckPath != "") {
+		return fmt.Errorf("--fail-on categories need a file-by-file comparison; use --fail-on any with --by-content, --dir-digest or --self-check")
+	}
+	if watchMode && (dirDigest || saveManifestPath != "") {
+		return fmt.Errorf("--watch cannot be combined with --dir-digest or --save-manifest")
+	}
+	if readBuffer <= 0 {
+		return fmt.Errorf("--read-buffer must be positive")
+	}
+	if hashWorkersA <= 0 || hashWorkersB <= 0 {
+		return fmt.Errorf("--hash-workers-a and --hash-workers-b must be positive")
+	}
+	if watchMode && poll <= 0 {
+		return fmt.Errorf("--poll must be a positive duration")
+	}
+	ignore, err := newIgnoreRules(ignoreGlobs, excludeExts)
+	if err != nil {
+		return err
+	}
+	skip, err := newSkipRules(skipRules)
+	if err != nil {
+		return err
+	}
+	hashExt, err := newExtPolicy(hashExts, noHashExts)
+	if err != nil {
+		return err
+	}
+	if hashExt.active() && (effectiveMode != "smart" || dirDigest || byContent) {
+		return fmt.Errorf("--hash-ext and --no-hash-ext apply to --hash-mode smart only")
+	}
+	filter, err := newPathFilter(filterPrefix)
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "jsonl" {
+		return fmt.Errorf("invalid --format: %s (use: text, jsonl)", format)
+	}
+	if format == "jsonl" && (dirDigest || watchMode || intraDup || selfCheckPath != "") {
+		return fmt.Errorf("--format jsonl cannot be combined with --dir-digest, --watch, --report-intra-dupes or --self-check")
+	}
+
+	var header string
+	switch {
+	case dirDigest:
+		header = "Running directory digest comparison: hashing every file (may be slow)."
+	case byContent:
+		header = "Running content-addressed comparison: hashing every file and grouping paths by content (may be slow)."
+	case effectiveMode == "off":
+		header = "Running in 'off' mode: path+size only (no hashing)."
+	case effectiveMode == "smart":
+		header = "Running in 'smart' mode: hashing only missing-by-path files."
+	case effectiveMode == "strict":
+		header = "Running in 'strict' mode: global content comparison (may be slow)."
+	default:
+		return fmt.Errorf("invalid hash-mode: %s (use: off, smart, strict)", effectiveMode)
+	}
+
+	switch compareWhat {
+	case "content":
+		header = "Comparing content only: files at the same path in both trees, hashing same-size pairs."
+		if quickCompare {
+			header = "Comparing content only: files at the same path in both trees, reading same-size pairs side by side."
+		}
+	case "both":
+		header += "\nAlso comparing the content of files at the same path in both trees."
 	}
 
 	var outFile *os.File
 	if outPath != "" {
 		var err error
-		outFile, err = os.Create(outPath)
+		if appendOut {
+			outFile, err = os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		} else {
+			outFile, err = os.Create(outPath)
+		}
 		if err != nil {
 			return err
 		}
 		defer outFile.Close()
 	}
 
+	opts := options{
+		mode:           mode,
+		compare:        compareWhat,
+		outFile:        outFile,
+		limit:          limit,
+		hardlinks:      !noHardlinkDedup,
+		byName:         byName,
+		intraDup:       intraDup,
+		readBuffer:     readBuffer,
+		ignore:         ignore,
+		skip:           skip,
+		hashExt:        hashExt,
+		filter:         filter,
+		normalize:      normalize,
+		minFiles:       minFiles,
+		sizeTolerance:  sizeTolerance,
+		fuzzyNames:     fuzzyNames,
+		parallelDrives: parallelDrives,
+		hashWorkersA:   hashWorkersA,
+		hashWorkersB:   hashWorkersB,
+		showPath:       showPath,
+		quickCompare:   quickCompare,
+		granularity:    granularity,
+	}
+	if format == "jsonl" {
+		// Records go to --out (or stdout); progress moves to stderr
+		records := os.Stdout
+		if outFile != nil {
+			records = outFile
+		}
+		opts.emit = jsonlEmitter(records)
+		opts.outFile = os.Stderr
+		outFile = os.Stderr
+	}
+
+	// Each appended run opens with a timestamped header; JSONL records stay
+	// bare so the file remains valid JSONL
+	logRuns := appendOut && opts.emit == nil
+	if logRuns {
+		output(outFile, fmt.Sprintf("\n===== twincheck run %s: %s =====", time.Now().Format("2006-01-02 15:04:05 -0700"), runSubject(driveA, driveB, compareManifest, selfCheckPath)))
+	}
+
+	if filter.active() {
+		output(outFile, fmt.Sprintf("Reporting only differences under %s.", filter.prefix))
+	}
+	if byName {
+		output(outFile, "Matching by base name + size, ignoring directories (files sharing a name may match spuriously).")
+	}
+
+	// Flags are valid; errors from here on are about the trees themselves
+	cmd.SilenceUsage = true
+
 	start := time.Now()
-	var err error
-	switch effectiveMode {
-	case "off":
-		output(outFile, "Running in 'off' mode: path+size only (no hashing).")
-		output(outFile, fmt.Sprintf("Scanning %s...", driveA))
-		filesA, _ := getFilesConcurrent(driveA)
-		output(outFile, fmt.Sprintf("Found %d files in %s", len(filesA), driveA))
-
-		output(outFile, fmt.Sprintf("Scanning %s...", driveB))
-		filesB, _ := getFilesConcurrent(driveB)
-		output(outFile, fmt.Sprintf("Found %d files in %s", len(filesB), driveB))
-		compareOff(filesA, filesB, mode, outFile)
-	case "smart":
-		output(outFile, "Running in 'smart' mode: hashing only missing-by-path files.")
-		err = compareSmart(driveA, driveB, mode, outFile)
-	case "strict":
-		output(outFile, "Running in 'strict' mode: global content comparison (may be slow).")
-		err = compareStrict(driveA, driveB, mode, outFile)
+	if selfCheckPath != "" {
+		diffs, err := selfCheck(driveA, selfCheckPath, opts)
+		if err != nil {
+			return err
+		}
+		if logRuns {
+			output(outFile, fmt.Sprintf("\nResult: %d differences", diffs))
+		}
+		output(outFile, fmt.Sprintf("\nDone in %v (read-only scan complete).\n", time.Since(start)))
+		if failOnDiff && diffs > 0 {
+			cmd.SilenceUsage = true
+			return errTreesDiffer
+		}
+		return nil
+	}
+	if driveB != "" {
+		output(outFile, header)
+	}
+
+	var a, b *tree
+	switch {
+	case listA != "" || listB != "":
+		if a, b, err = loadListPair(driveA, listA, compareManifest, driveB, listB, dirDigest || byContent || effectiveMode != "off" || compareWhat != "structure", opts); err != nil {
+			return err
+		}
+	case compareManifest != "":
+		if a, err = loadManifest(compareManifest, dirDigest || byContent || effectiveMode != "off" || compareWhat != "structure", opts); err != nil {
+			return err
+		}
+		if b, err = scanTree(driveB, opts); err != nil {
+			return err
+		}
+	case driveB != "":
+		if a, b, err = scanTreePair(driveA, driveB, opts); err != nil {
+			return err
+		}
 	default:
-		return fmt.Errorf("invalid hash-mode: %s (use: off, smart, strict)", effectiveMode)
+		if a, err = scanTree(driveA, opts); err != nil {
+			return err
+		}
+	}
+	defer a.close()
+	defer b.close()
+	if s := opts.skip.summary(applySkip(a, b, opts)); s != "" {
+		output(outFile, "  "+s)
+	}
+	a.workers = opts.hashWorkersA
+	if b != nil {
+		b.workers = opts.hashWorkersB
 	}
 
-	if err != nil {
-		return err
+	if saveManifestPath != "" {
+		output(outFile, fmt.Sprintf("Hashing %d files for manifest...", len(a.files)))
+		if err := saveManifest(saveManifestPath, a); err != nil {
+			return err
+		}
+		output(outFile, fmt.Sprintf("Saved manifest of %s to %s", redactRoot(driveA), saveManifestPath))
+		if driveB == "" {
+			reportDenied(outFile, a)
+			output(outFile, fmt.Sprintf("\nDone in %v (read-only scan complete).\n", time.Since(start)))
+			return nil
+		}
 	}
 
+	if watchMode {
+		cache := newHashCache()
+		a.cache, b.cache = cache, cache
+	}
+
+	var differ bool
+	if byContent {
+		diffs := compareByContent(a, b, opts)
+		differ = diffs > 0
+		if logRuns {
+			output(outFile, fmt.Sprintf("\nResult: %d differences", diffs))
+		}
+	} else if dirDigest {
+		if differ, err = compareDirDigests(a, b, opts); err != nil {
+			return err
+		}
+		if logRuns && differ {
+			output(outFile, "\nResult: trees differ")
+		} else if logRuns {
+			output(outFile, "\nResult: trees identical")
+		}
+	} else {
+		res, err := compare(a, b, effectiveMode, opts)
+		if err != nil {
+			return err
+		}
+		if opts.emit == nil {
+			report(res, opts)
+			if effectiveMode != "off" || compareWhat != "structure" {
+				estimateStorage(a, b, res).write(opts)
+			}
+		}
+		differ = failPol.trips(res)
+		if logRuns {
+			output(outFile, fmt.Sprintf("\nResult: %d differences", res.diffs))
+		}
+
+		if watchMode {
+			reportDenied(outFile, a, b)
+			return watch(a, b, effectiveMode, poll, res, opts)
+		}
+	}
+
+	reportDenied(outFile, a, b)
 	elapsed := time.Since(start)
 	output(outFile, fmt.Sprintf("\nDone in %v (read-only scan complete).\n", elapsed))
+	if failOnDiff && differ {
+		cmd.SilenceUsage = true
+		return errTreesDiffer
+	}
 	return nil
 }
 
+// runSubject names what a run compares, for the --append header.
+func runSubject(driveA, driveB, compareManifest, selfCheckPath string) string {
+	driveA, driveB = redactRoot(driveA), redactRoot(driveB)
+	switch {
+	case selfCheckPath != "":
+		return fmt.Sprintf("%s against %s", driveA, selfCheckPath)
+	case compareManifest != "":
+		return fmt.Sprintf("%s vs %s", compareManifest, driveB)
+	case driveB == "":
+		return driveA
+	}
+	return fmt.Sprintf("%s vs %s", driveA, driveB)
+}
+
+// errTreesDiffer makes --fail-on-diff exit non-zero, like diff(1).
+var errTreesDiffer = errors.New("trees differ")
+
 var Cmd = &cobra.Command{
 	Use:   "twincheck",
 	Short: "Compare two directory trees with configurable hash behavior",
@@ -537,10 +1324,45 @@ var Cmd = &cobra.Command{
 }
 
 func init() {
-	Cmd.Flags().StringP("a", "a", "", "path to Drive A (required)")
-	Cmd.Flags().StringP("b", "b", "", "path to Drive B (required)")
+	Cmd.Flags().StringP("a", "a", "", "path to Drive A, or sftp://[user@]host[:port]/path (required)")
+	Cmd.Flags().StringP("b", "b", "", "path to Drive B, or sftp://[user@]host[:port]/path (required)")
 	Cmd.Flags().StringP("mode", "m", "all", "comparison mode: all | missing_a | missing_b")
+	Cmd.Flags().String("compare", "structure", "what to compare: structure (which paths exist) | content (same-path files whose content differs) | both")
 	Cmd.Flags().StringP("out", "o", "", "optional output file")
+	Cmd.Flags().Bool("append", false, "append to --out instead of overwriting it, with a timestamped header and result line per run")
 	Cmd.Flags().BoolP("hash", "H", false, "shorthand for --hash-mode=smart")
 	Cmd.Flags().String("hash-mode", "off", "hashing behavior: off | smart | strict")
+	Cmd.Flags().Bool("no-hardlink-dedup", false, "count and hash every hardlinked path separately (default: one file per inode)")
+	Cmd.Flags().Bool("by-name", false, "off/smart: match files by base name + size anywhere in the other tree, ignoring directories")
+	Cmd.Flags().Bool("by-content", false, "hash everything and group paths by content, showing where each content lives in A and in B regardless of path or name")
+	Cmd.Flags().Bool("dir-digest", false, "hash everything and report which subdirectories are identical or differ")
+	Cmd.Flags().Bool("report-intra-dupes", false, "strict: also list same-content files within each tree (among hashed files)")
+	Cmd.Flags().String("save-manifest", "", "hash Tree A and save a snapshot manifest to this file (-b becomes optional)")
+	Cmd.Flags().String("compare-manifest", "", "use a saved manifest as Tree A instead of -a")
+	Cmd.Flags().String("list-a", "", "read Tree A from a text file of paths (path or path<TAB>size per line) instead of scanning; -a, if given, is the root they are read from")
+	Cmd.Flags().String("list-b", "", "read Tree B from a text file of paths, like --list-a; -b, if given, is their root")
+	Cmd.Flags().String("self-check", "", "verify -a against an earlier manifest of itself: report added, removed, modified and silently corrupted files")
+	Cmd.Flags().Bool("watch", false, "after the first comparison, keep polling both trees and print only what changed (Ctrl-C to stop)")
+	Cmd.Flags().Duration("poll", 5*time.Second, "polling interval for --watch")
+	Cmd.Flags().StringArray("ignore", nil, "glob of files to leave out of both trees, matched against the name or relative path (repeatable)")
+	Cmd.Flags().StringArray("skip", nil, "leave out files matching a glob AND smaller than a size, e.g. \"*.jpg<100K\" for thumbnails (repeatable, case-insensitive)")
+	Cmd.Flags().StringSlice("exclude-ext", nil, "file extensions to leave out of both trees, case-insensitive (repeatable, e.g. .log,.tmp)")
+	Cmd.Flags().Bool("parallel-drives", false, "hash Tree A and Tree B at the same time; faster when they are on separate disks, slower when they share one")
+	Cmd.Flags().Int("hash-workers-a", defaultHashWorkers, "files hashed in parallel from Tree A; use 2-4 for a spinning disk to avoid seek thrashing (--quick-compare uses the lower of -a and -b)")
+	Cmd.Flags().Int("hash-workers-b", defaultHashWorkers, "files hashed in parallel from Tree B; use 2-4 for a spinning disk to avoid seek thrashing")
+	Cmd.Flags().Int("read-buffer", defaultReadBuffer, "read buffer size in bytes used per hashing worker; the block size of --quick-compare")
+	Cmd.Flags().StringSlice("hash-ext", nil, "smart: only hash files with these extensions; others are judged by path+size (repeatable, e.g. .jpg,.mp4)")
+	Cmd.Flags().StringSlice("no-hash-ext", nil, "smart: never hash files with these extensions; they are judged by path+size (repeatable, e.g. .log)")
+	Cmd.Flags().Bool("normalize-unicode", false, "compare file names by their Unicode NFC form, so names decomposed by macOS (NFD) match the same names from Linux/Windows")
+	Cmd.Flags().String("filter-prefix", "", "report only differences whose relative path is under this subdirectory (e.g. Photos/2023); trees are still scanned in full")
+	Cmd.Flags().String("granularity", "file", "level of the only-in-A/B lists: file | dir (containing directories with file counts) | top (first-level directories)")
+	Cmd.Flags().Bool("quick-compare", false, "with --compare content or both, read same-path same-size pairs side by side and stop at the first difference, reporting its byte offset, instead of hashing them")
+	Cmd.Flags().String("show-path", "both", "for moved, changed and near-match entries print Tree A's path, Tree B's path, or both: a | b | both")
+	Cmd.Flags().String("format", "text", "output format: text | jsonl (one JSON object per difference, streamed as found)")
+	Cmd.Flags().Bool("fuzzy-names", false, "FUZZY: pair a file missing from one tree with a same-size file in the other whose path matches ignoring case, accents, whitespace and punctuation, and report them as probable matches")
+	Cmd.Flags().Float64("size-tolerance", 0, "APPROXIMATE: report files at the same path whose sizes differ by at most this percent as near matches instead of differences; smart mode also pairs one-sided files of the same extension by near size (0 = off)")
+	Cmd.Flags().Int("min-files", 0, "abort if either tree has fewer than N files, e.g. a mistyped path or unmounted drive (0 = no check)")
+	Cmd.Flags().Bool("fail-on-diff", false, "exit with a non-zero status when any difference is found")
+	Cmd.Flags().StringSlice("fail-on", nil, "exit non-zero only for these differences (implies --fail-on-diff): any, missing_b (only in A), missing_a (only in B), moved, changed, unreadable")
+	Cmd.Flags().Int("limit", 0, "show at most N entries per section on the console (0 = all; --out always gets the full list)")
 }
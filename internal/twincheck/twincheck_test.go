@@ -0,0 +1,153 @@
+package twincheck
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// writeRandomFile creates dir/name holding size bytes of random data.
+func writeRandomFile(tb testing.TB, dir, name string, size int64) {
+	tb.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, rand.New(rand.NewSource(size)), size); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// BenchmarkHashFile compares io.Copy's 32 KB buffer with the 1 MB
+// defaultReadBuffer on one large file. The first iteration warms the page
+// cache, so the numbers show the per-call overhead rather than disk speed.
+func BenchmarkHashFile(b *testing.B) {
+	const size = 256 << 20
+	dir := b.TempDir()
+	writeRandomFile(b, dir, "big.bin", size)
+	fsys := localFS{base: dir}
+
+	for _, bufSize := range []int{32 << 10, defaultReadBuffer} {
+		b.Run(strconv.Itoa(bufSize>>10)+"KB", func(b *testing.B) {
+			buf := make([]byte, bufSize)
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				if _, err := hashFile(fsys, "big.bin", buf, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// writeWideTree creates dirs directories of perDir small files each, some
+// nested a level deeper, and returns the root.
+func writeWideTree(tb testing.TB, dirs, perDir int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for d := 0; d < dirs; d++ {
+		dir := fmt.Sprintf("dir%03d", d)
+		if d%4 == 0 {
+			dir = filepath.Join(dir, "nested")
+		}
+		for f := 0; f < perDir; f++ {
+			writeRandomFile(tb, root, filepath.Join(dir, fmt.Sprintf("file%03d.bin", f)), int64(d*perDir+f))
+		}
+	}
+	return root
+}
+
+// walkFiles lists root the simple way, for checking getFilesConcurrent.
+func walkFiles(tb testing.TB, root string) FileMap {
+	tb.Helper()
+	files := make(FileMap)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = info.Size()
+		return nil
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return files
+}
+
+func TestGetFilesConcurrentMatchesWalk(t *testing.T) {
+	root := writeWideTree(t, 40, 25)
+	got, _, denied, err := getFilesConcurrent(localFS{base: root}, root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denied) > 0 {
+		t.Fatalf("denied directories: %v", denied)
+	}
+	if want := walkFiles(t, root); !reflect.DeepEqual(got, want) {
+		t.Fatalf("getFilesConcurrent found %d files, WalkDir %d; the maps differ", len(got), len(want))
+	}
+}
+
+func BenchmarkGetFilesConcurrent(b *testing.B) {
+	root := writeWideTree(b, 200, 50)
+	fsys := localFS{base: root}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := getFilesConcurrent(fsys, root, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHashPair hashes two trees in turn and with --parallel-drives.
+// Both temp trees share one disk here, so this measures the overlap's
+// overhead rather than the gain on separate drives.
+func BenchmarkHashPair(b *testing.B) {
+	rootA, rootB := b.TempDir(), b.TempDir()
+	for i := 0; i < 64; i++ {
+		name := fmt.Sprintf("file%02d.bin", i)
+		writeRandomFile(b, rootA, name, 1<<20+int64(i))
+		writeRandomFile(b, rootB, name, 1<<20+int64(i))
+	}
+	var opts options
+	treeA, _, _, err := loadTree(localFS{base: rootA}, rootA, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	treeB, _, _, err := loadTree(localFS{base: rootB}, rootB, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pathsA, pathsB := treeA.allPaths(), treeB.allPaths()
+
+	for _, parallel := range []bool{false, true} {
+		b.Run("parallel="+strconv.FormatBool(parallel), func(b *testing.B) {
+			opts.parallelDrives = parallel
+			for i := 0; i < b.N; i++ {
+				_, errsA, _, errsB := hashPair(treeA, pathsA, treeB, pathsB, opts)
+				if len(errsA)+len(errsB) > 0 {
+					b.Fatalf("unreadable files: %v %v", errsA, errsB)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package twincheck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// rescan refreshes a live tree without printing progress. Manifest trees
+// are returned unchanged. On error the caller keeps the previous tree.
+func rescan(t *tree, opts options) (*tree, error) {
+	if t.hashes != nil {
+		return t, nil
+	}
+	fresh, _, _, err := loadTree(t.fsys, t.base, opts)
+	if err != nil {
+		return nil, err
+	}
+	fresh.cache, fresh.bufSz, fresh.workers = t.cache, t.bufSz, t.workers
+	return fresh, nil
+}
+
+// delta returns the entries added to and removed from a sorted list.
+func delta(prev, cur []string) (added, removed []string) {
+	before := make(map[string]bool, len(prev))
+	for _, p := range prev {
+		before[p] = true
+	}
+	after := make(map[string]bool, len(cur))
+	for _, p := range cur {
+		after[p] = true
+		if !before[p] {
+			added = append(added, p)
+		}
+	}
+	for _, p := range prev {
+		if !after[p] {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// watch re-runs the comparison every interval until interrupted, printing
+// only how the result changed since the previous iteration. Trees carry a
+// hash cache so unchanged files are not re-read.
+func watch(a, b *tree, hashMode string, interval time.Duration, prev result, opts options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	output(opts.outFile, fmt.Sprintf("\nWatching for changes every %v (Ctrl-C to stop)...", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			output(opts.outFile, "Stopped watching.")
+			return nil
+		case <-ticker.C:
+		}
+
+		// A tree that cannot be listed (e.g. an unplugged drive) would look
+		// like every file was removed; skip this poll instead
+		freshA, errA := rescan(a, opts)
+		freshB, errB := rescan(b, opts)
+		if errA != nil || errB != nil {
+			for _, err := range []error{errA, errB} {
+				if err != nil {
+					output(opts.outFile, fmt.Sprintf("\n--- %s: rescan failed, keeping the previous result: %v ---",
+						time.Now().Format("15:04:05"), err))
+				}
+			}
+			continue
+		}
+		a, b = freshA, freshB
+		applySkip(a, b, opts)
+		res, err := compare(a, b, hashMode, opts)
+		if err != nil {
+			return err
+		}
+
+		addedA, resolvedA := delta(prev.onlyA, res.onlyA)
+		addedB, resolvedB := delta(prev.onlyB, res.onlyB)
+		prev = res
+		if len(addedA)+len(resolvedA)+len(addedB)+len(resolvedB) == 0 {
+			continue
+		}
+
+		output(opts.outFile, fmt.Sprintf("\n--- %s: %d only in A, %d only in B ---",
+			time.Now().Format("15:04:05"), len(res.onlyA), len(res.onlyB)))
+		for _, p := range addedA {
+			output(opts.outFile, "+A "+p)
+		}
+		for _, p := range resolvedA {
+			output(opts.outFile, "-A "+p)
+		}
+		for _, p := range addedB {
+			output(opts.outFile, "+B "+p)
+		}
+		for _, p := range resolvedB {
+			output(opts.outFile, "-B "+p)
+		}
+	}
+}